@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var clearCacheCmd = &cobra.Command{
+	Use:   "clear-cache",
+	Short: "Clear the extraction cache",
+	Long: util.Dedent(`
+		Remove all the extracted text stored by 'scan --cache'. The indexed
+		documents are not affected.
+	`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		count, err := db.ClearCache()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d cached document(s).\n", count)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(clearCacheCmd)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+)
+
+// Values of the --color flag
+const (
+	colorAlways = "always"
+	colorAuto   = "auto"
+	colorNever  = "never"
+)
+
+// colorMode is the value of the global --color flag
+var colorMode string
+
+// setupColor configures the lipgloss color profile for the --color mode
+func setupColor() error {
+	switch colorMode {
+	case colorAlways:
+		// Keep the detected profile on a terminal, but force colors when piped
+		if !term.IsTerminal(os.Stdout.Fd()) {
+			lipgloss.SetColorProfile(termenv.ANSI256)
+		}
+	case colorAuto:
+		if !term.IsTerminal(os.Stdout.Fd()) {
+			lipgloss.SetColorProfile(termenv.Ascii)
+		}
+	case colorNever:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	default:
+		return fmt.Errorf("invalid --color %q, expected one of: always, auto, never", colorMode)
+	}
+	return nil
+}
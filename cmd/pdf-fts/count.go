@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var countCmd = &cobra.Command{
+	Use:   "count <query>",
+	Short: "Count pages and documents matching a query",
+	Long: util.Dedent(`
+		Count how many pages and distinct documents match a full-text query,
+		without fetching any snippet. The query is read like search does by
+		default.
+	`),
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := strings.Join(args, " ")
+
+		matchQuery, err := defaultMatchQuery(query)
+		if err != nil {
+			return err
+		}
+
+		pages, docs, err := db.CountMatches(matchQuery)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%d page(s) in %d document(s) match '%s'.\n", pages, docs, query)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(countCmd)
+	addDatabaseFlag(countCmd)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runScanDaemon rescans the folders every opts.Interval until interrupted. An
+// interrupt during a cycle lets it finish, a second one aborts immediately.
+// Errors of a cycle are reported and the next cycle runs anyway.
+func runScanDaemon(folders []string, opts scanOptions) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scanning := make(chan bool, 1)
+	go func() {
+		<-ctx.Done()
+		// Restore the default handling so another Ctrl-C kills the process
+		stop()
+		select {
+		case <-scanning:
+			fmt.Fprintln(os.Stderr, "\nInterrupted, finishing the current scan (press Ctrl-C again to abort)...")
+		default:
+		}
+	}()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	var total scanStats
+	cycles := 0
+	for {
+		cycles++
+		started := time.Now()
+		fmt.Printf("Scan cycle %d started at %s\n\n", cycles, started.Format(time.DateTime))
+
+		scanning <- true
+		stats, err := scanFolders(folders, opts)
+		select {
+		case <-scanning:
+		default:
+		}
+
+		total.Found = stats.Found
+		total.Updated += stats.Updated
+		total.Failed += stats.Failed
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Scan cycle %d failed: %v\n", cycles, err)
+		}
+		fmt.Printf("\nScan cycle %d done in %s: %d PDFs, %d updated, %d failed.\n",
+			cycles, time.Since(started).Round(time.Millisecond), stats.Found, stats.Updated, stats.Failed)
+
+		if ctx.Err() == nil {
+			fmt.Printf("Next scan in %s.\n\n", opts.Interval)
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Printf("\nStopped after %d cycle(s): %d updates and %d failures in total, %d PDFs in the last cycle.\n",
+				cycles, total.Updated, total.Failed, total.Found)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
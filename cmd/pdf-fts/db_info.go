@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/spf13/cobra"
+)
+
+// dbInfo is set by the global --db-info flag
+var dbInfo bool
+
+// errDBInfoPrinted stops a command after --db-info printed its database
+var errDBInfoPrinted = errors.New("database info printed")
+
+// printDBInfo prints the database a command would use from the current
+// directory, with the same resolution as the command itself, without opening
+// it. With --verbose the discovery logs each directory looked at.
+func printDBInfo(cmd *cobra.Command) error {
+	var source string
+	explicit := ""
+	if flag := cmd.Flags().Lookup("database"); flag != nil && flag.Value.String() != "" {
+		explicit, source = flag.Value.String(), "given with --database"
+	} else if paths, err := cmd.Flags().GetStringArray("db"); err == nil && len(paths) > 0 {
+		explicit, source = paths[0], "the first --db"
+	}
+
+	switch {
+	case database.IsMemory(explicit):
+		fmt.Printf("Database: %s\n", explicit)
+		fmt.Printf("Source:   %s, in memory and discarded when the command ends\n", source)
+		return nil
+	case explicit != "":
+		absPath, err := filepath.Abs(explicit)
+		if err != nil {
+			return fmt.Errorf("resolving database path: %w", err)
+		}
+		cfg.DBPath = absPath
+	case cmd.Name() == "selftest" || cmd.Name() == "export-page":
+		fmt.Printf("The %s command doesn't use a database\n", cmd.Name())
+		return nil
+	default:
+		if err := cfg.FindExistingDBPath(); err == nil {
+			source = "found from the working directory"
+		} else if requiresExistingDB(cmd.Name()) {
+			fmt.Println("No database found, the command would fail until 'scan' creates one")
+			return nil
+		} else {
+			if err := cfg.CreateDBPath(); err != nil {
+				return err
+			}
+			source = "not found, it would be created in the working directory"
+		}
+	}
+
+	fmt.Printf("Database: %s\n", cfg.DBPath)
+	fmt.Printf("Source:   %s\n", source)
+	info, err := os.Stat(cfg.DBPath)
+	if err != nil {
+		fmt.Println("Exists:   no")
+		return nil
+	}
+	fmt.Println("Exists:   yes")
+	fmt.Printf("Size:     %s\n", formatFileSize(info.Size()))
+	return nil
+}
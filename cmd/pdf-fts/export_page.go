@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/pdf"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var exportPageCmd = &cobra.Command{
+	Use:   "export-page <path> <page>",
+	Short: "Render a page of a PDF to an image",
+	Long: util.Dedent(`
+		Render a page of a PDF file (starting at 1) to a PNG or JPEG image, chosen
+		by the extension of --out, to visually check a match. The file doesn't
+		need to be indexed. See also 'search --export-matches' to export all the
+		matching pages of a search.
+	`),
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := filepath.Clean(args[0])
+		pageNum, err := strconv.Atoi(args[1])
+		if err != nil || pageNum < 1 {
+			return fmt.Errorf("invalid page %q, expected a number starting at 1", args[1])
+		}
+
+		out, _ := cmd.Flags().GetString("out")
+		if out == "" {
+			out = pdf.PageImageName(path, pageNum, ".png")
+		}
+		dpi, _ := cmd.Flags().GetFloat64("dpi")
+		if dpi <= 0 {
+			return fmt.Errorf("--dpi must be positive")
+		}
+
+		if err := pdf.ExportPage(path, pageNum, dpi, out); err != nil {
+			return err
+		}
+		fmt.Printf("Exported page %d of %s to %s\n", pageNum, path, out)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportPageCmd)
+	exportPageCmd.Flags().StringP("out", "o", "", "image file to write, .png, .jpg or .jpeg (default: <name>-p<page>.png)")
+	exportPageCmd.Flags().Float64("dpi", pdf.DefaultDPI, "resolution of the image")
+}
+
+// exportMatches renders each matching page of a search to a PNG image in the
+// --export-matches directory, named after the file and the page. It reports
+// on stderr, leaving stdout to the search output.
+func exportMatches(matchQuery, queryTerm string, dbOpts database.SearchOptions, opts searchOptions, capped bool) error {
+	type pageRef struct {
+		path string
+		page int
+	}
+	var pages []pageRef
+	seen := make(map[pageRef]bool)
+	_, err := eachResult(matchQuery, queryTerm, dbOpts, opts, capped, func(result database.SearchResult) error {
+		ref := pageRef{result.Path, result.PageNum}
+		if !seen[ref] {
+			seen[ref] = true
+			pages = append(pages, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.ExportMatches, 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", opts.ExportMatches, err)
+	}
+
+	// Files with the same name in different folders get a numbered suffix
+	names := make(map[string]int)
+	exported := 0
+	for _, ref := range pages {
+		name := pdf.PageImageName(ref.path, ref.page, ".png")
+		names[name]++
+		if n := names[name]; n > 1 {
+			name = pdf.PageImageName(ref.path, ref.page, fmt.Sprintf("-%d.png", n))
+		}
+
+		out := filepath.Join(opts.ExportMatches, name)
+		if err := pdf.ExportPage(ref.path, ref.page, opts.ExportDPI, out); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to export page %d of %s: %v\n", ref.page, ref.path, err)
+			continue
+		}
+		if cfg.Verbose {
+			log.Printf("Exported page %d of %s to %s", ref.page, ref.path, out)
+		}
+		exported++
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d page image(s) to %s\n", exported, opts.ExportMatches)
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/pdf"
+)
+
+// searchDB is a database searched by a federated search
+type searchDB struct {
+	path string
+	db   *database.DB
+}
+
+// searchDBs are the databases given with repeated --db flags, the first one
+// is the global db. It is empty for a search of a single database.
+var searchDBs []searchDB
+
+// openSearchDatabases opens read-only the databases of a federated search
+// besides the global db, which must be the first of paths. Databases indexed
+// with a different schema or chunk mode are searched anyway, with a warning as
+// their scores and pages aren't quite comparable.
+func openSearchDatabases(paths []string) error {
+	primaryChunks, err := chunkMode(db)
+	if err != nil {
+		return err
+	}
+
+	searchDBs = []searchDB{{path: cfg.DBPath, db: db}}
+	for _, path := range paths[1:] {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("resolving database path %s: %w", path, err)
+		}
+		if slices.ContainsFunc(searchDBs, func(s searchDB) bool { return s.path == absPath }) {
+			continue
+		}
+
+		other, err := database.NewReadOnly(absPath, databaseOptions())
+		if err != nil {
+			closeSearchDatabases()
+			return fmt.Errorf("opening %s: %w", path, err)
+		}
+		searchDBs = append(searchDBs, searchDB{path: absPath, db: other})
+
+		if stored, err := other.StoredSchemaVersion(); err == nil && stored != database.SchemaVersion {
+			fmt.Fprintf(os.Stderr, "Warning: %s was indexed with schema %d (current %d), its results may differ\n",
+				path, stored, database.SchemaVersion)
+		}
+		if chunks, err := chunkMode(other); err == nil && chunks != primaryChunks {
+			fmt.Fprintf(os.Stderr, "Warning: %s was indexed with a different --chunk mode, its scores aren't comparable\n", path)
+		}
+	}
+	return nil
+}
+
+// chunkMode returns the chunk mode a database was indexed with
+func chunkMode(d *database.DB) (string, error) {
+	mode, err := d.GetMeta(chunkModeMeta)
+	if mode == "" {
+		mode = pdf.ChunkPage
+	}
+	return mode, err
+}
+
+// closeSearchDatabases closes the databases opened by openSearchDatabases,
+// the global db is closed as usual
+func closeSearchDatabases() {
+	for _, s := range searchDBs {
+		if s.db != db {
+			s.db.Close()
+		}
+	}
+	searchDBs = nil
+}
+
+// resultDB returns the database a result comes from
+func resultDB(result database.SearchResult) *database.DB {
+	for _, s := range searchDBs {
+		if s.path == result.Database {
+			return s.db
+		}
+	}
+	return db
+}
+
+// sameFile reports whether two results come from the same file of the same database
+func sameFile(a, b database.SearchResult) bool {
+	return a.Path == b.Path && a.Database == b.Database
+}
+
+// searchVolumes returns the volumes of all the searched databases by path
+func searchVolumes() (map[string]database.Volume, error) {
+	if len(searchDBs) == 0 {
+		return db.Volumes()
+	}
+
+	volumes := make(map[string]database.Volume)
+	for _, s := range searchDBs {
+		dbVolumes, err := s.db.Volumes()
+		if err != nil {
+			return nil, err
+		}
+		for path, volume := range dbVolumes {
+			volumes[path] = volume
+		}
+	}
+	return volumes, nil
+}
+
+// searchEach runs the search on the global db, or on every database of a
+// federated search
+func searchEach(matchQuery string, dbOpts database.SearchOptions, fn func(database.SearchResult) error) error {
+	if len(searchDBs) == 0 {
+		return db.SearchEach(matchQuery, dbOpts, fn)
+	}
+	return federatedSearchEach(matchQuery, dbOpts, fn)
+}
+
+// federatedSearchEach runs the search on each database, tags the results with
+// their database and merges them by rank (or length with SortByLength), then
+// applies the limit and offset to the merged list. Results are collected
+// first, so unlike a single database nothing is streamed.
+func federatedSearchEach(matchQuery string, dbOpts database.SearchOptions, fn func(database.SearchResult) error) error {
+	// Each database returns enough results to fill the page of the merged list
+	perDB := dbOpts
+	perDB.Offset = 0
+	if dbOpts.Limit > 0 {
+		perDB.Limit = dbOpts.Offset + dbOpts.Limit
+	}
+
+	var results []database.SearchResult
+	for _, s := range searchDBs {
+		err := s.db.SearchEach(matchQuery, perDB, func(result database.SearchResult) error {
+			result.Database = s.path
+			results = append(results, result)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("searching %s: %w", s.path, err)
+		}
+	}
+
+	better := func(a, b database.SearchResult) bool {
+		if dbOpts.SortByLength && a.Length != b.Length {
+			return a.Length > b.Length
+		}
+		return a.Score < b.Score
+	}
+
+	if !dbOpts.LimitFiles || dbOpts.DistinctFiles {
+		sort.SliceStable(results, func(i, j int) bool { return better(results[i], results[j]) })
+		results = pageOf(results, dbOpts.Offset, dbOpts.Limit)
+	} else {
+		// Files are ranked by their best page and keep their pages in order
+		var files [][]database.SearchResult
+		for _, result := range results {
+			if n := len(files); n > 0 && sameFile(files[n-1][0], result) {
+				files[n-1] = append(files[n-1], result)
+			} else {
+				files = append(files, []database.SearchResult{result})
+			}
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			return better(bestResult(files[i], better), bestResult(files[j], better))
+		})
+
+		results = nil
+		for _, file := range pageOf(files, dbOpts.Offset, dbOpts.Limit) {
+			results = append(results, file...)
+		}
+	}
+
+	for _, result := range results {
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bestResult returns the best of the results of a file
+func bestResult(results []database.SearchResult, better func(a, b database.SearchResult) bool) database.SearchResult {
+	best := results[0]
+	for _, result := range results[1:] {
+		if better(result, best) {
+			best = result
+		}
+	}
+	return best
+}
+
+// pageOf returns the items selected by an offset and a limit, all the
+// remaining ones when the limit is zero or less
+func pageOf[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
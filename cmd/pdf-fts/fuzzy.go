@@ -0,0 +1,123 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+)
+
+// suggestionPages is the number of pages sampled to build the vocabulary for suggestions
+const suggestionPages = 20
+
+// fuzzyMatchQuery builds a MATCH expression matching any of the trigrams of
+// the query words. As the index uses the trigram tokenizer, pages sharing more
+// trigrams with the query rank higher, which tolerates small typos.
+func fuzzyMatchQuery(queryTerm string) string {
+	var trigrams []string
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(queryTerm)) {
+		for _, trigram := range wordTrigrams(word) {
+			if !seen[trigram] {
+				seen[trigram] = true
+				trigrams = append(trigrams, quoteFTSTerm(trigram))
+			}
+		}
+	}
+	return strings.Join(trigrams, " OR ")
+}
+
+// wordTrigrams returns the overlapping 3-rune substrings of a word, or the word
+// itself if it is shorter than that
+func wordTrigrams(word string) []string {
+	runes := []rune(word)
+	if len(runes) <= 3 {
+		return []string{word}
+	}
+
+	trigrams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams = append(trigrams, string(runes[i:i+3]))
+	}
+	return trigrams
+}
+
+// suggestTerms returns indexed words close to the query words, for a "did you
+// mean" hint. The vocabulary is taken from the pages that best match the
+// query trigrams, so it costs one extra fuzzy query per query word.
+func suggestTerms(queryTerm string, maxSuggestions int) ([]string, error) {
+	type candidate struct {
+		word     string
+		distance int
+		count    int
+	}
+
+	var suggestions []string
+	for _, term := range suggestionWords(queryTerm) {
+		if len([]rune(term)) < 3 {
+			continue
+		}
+
+		contents, err := db.MatchingContent(fuzzyMatchQuery(term), suggestionPages)
+		if err != nil {
+			return nil, err
+		}
+
+		maxDistance := max(1, len([]rune(term))/3)
+		candidates := make(map[string]*candidate)
+		for _, content := range contents {
+			for _, word := range splitWords(strings.ToLower(content)) {
+				if c, ok := candidates[word]; ok {
+					c.count++
+					continue
+				}
+				if word == term {
+					continue
+				}
+				if distance := util.Levenshtein(word, term); distance <= maxDistance {
+					candidates[word] = &candidate{word: word, distance: distance, count: 1}
+				}
+			}
+		}
+
+		sorted := make([]*candidate, 0, len(candidates))
+		for _, c := range candidates {
+			sorted = append(sorted, c)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].distance != sorted[j].distance {
+				return sorted[i].distance < sorted[j].distance
+			}
+			return sorted[i].count > sorted[j].count
+		})
+
+		for i := 0; i < len(sorted) && i < maxSuggestions; i++ {
+			suggestions = append(suggestions, sorted[i].word)
+		}
+	}
+
+	return suggestions, nil
+}
+
+// suggestionWords returns the lowercase words of a query to find suggestions
+// for, split like the page content and without the FTS operators, so quotes,
+// stars and parentheses don't end up in the suggestions
+func suggestionWords(queryTerm string) []string {
+	var words []string
+	for _, word := range splitWords(queryTerm) {
+		switch word {
+		case "AND", "OR", "NOT", "NEAR":
+			continue
+		}
+		words = append(words, strings.ToLower(word))
+	}
+	return words
+}
+
+// splitWords splits text into runs of letters and digits
+func splitWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
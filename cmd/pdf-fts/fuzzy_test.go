@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestionWords(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Neural Netwrk", []string{"neural", "netwrk"}},
+		{`"machine lerning" OR deep`, []string{"machine", "lerning", "deep"}},
+		{"NEAR(neurl network) NOT cat*", []string{"neurl", "network", "cat"}},
+		{"and or not", []string{"and", "or", "not"}},
+		{"v1.2", []string{"v1", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := suggestionWords(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
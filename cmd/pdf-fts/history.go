@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the recent scan runs",
+	Long: util.Dedent(`
+		List the most recent scans with their duration, the number of files
+		found, processed and failed and the size of the files processed, to
+		follow how scan time grows with the library.
+	`),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		runs, err := db.ScanRuns(limit)
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			fmt.Println("No scans recorded yet.")
+			return nil
+		}
+
+		fmt.Printf("%-16s  %10s  %6s  %9s  %6s  %10s  %10s\n",
+			"Started", "Duration", "Found", "Processed", "Failed", "Size", "Throughput")
+		for _, run := range runs {
+			throughput := "-"
+			if seconds := run.Duration.Seconds(); run.Bytes > 0 && seconds > 0 {
+				throughput = formatFileSize(int64(float64(run.Bytes)/seconds)) + "/s"
+			}
+			fmt.Printf("%-16s  %10s  %6d  %9d  %6d  %10s  %10s\n",
+				run.StartedAt.Local().Format("2006-01-02 15:04"),
+				run.Duration.Round(time.Millisecond),
+				run.Found, run.Processed, run.Failed,
+				formatFileSize(run.Bytes), throughput)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	addDatabaseFlag(historyCmd)
+	historyCmd.Flags().IntP("limit", "l", 20, "number of runs shown, 0 for all")
+}
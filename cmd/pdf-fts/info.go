@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/pdf"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+// imageOnlyChars is the average number of extracted characters per page below
+// which a document probably has no text layer (e.g. a scan without OCR)
+const imageOnlyChars = 20
+
+// looksImageOnly reports whether too little text was extracted from a
+// document for its number of pages
+func looksImageOnly(pages, characters int) bool {
+	return pages > 0 && characters/pages < imageOnlyChars
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info <path>",
+	Short: "Show what is known about an indexed document",
+	Long: util.Dedent(`
+		Print the stored record of a single document: its hash, page count, last
+		scan time, the size and modification time of the file on disk, how much
+		text was extracted and whether it looks image-only. Useful to understand
+		why a file does or doesn't show up in searches.
+	`),
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := filepath.Clean(args[0])
+
+		info, err := db.DocumentInfo(path, pdf.LowQuality)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			return fmt.Errorf("%s is not indexed, paths are stored as given to 'scan'", path)
+		}
+
+		fmt.Printf("Path:          %s\n", info.Path)
+		fmt.Printf("Hash:          %s\n", info.Hash)
+		fmt.Printf("Pages:         %d\n", info.Pages)
+		if info.Rows != info.Pages {
+			fmt.Printf("Segments:      %d\n", info.Rows)
+		}
+		fmt.Printf("Last scanned:  %s\n", info.LastScanned)
+
+		if stat, err := os.Stat(info.Path); err == nil {
+			fmt.Printf("File size:     %s\n", formatFileSize(stat.Size()))
+			fmt.Printf("Modified:      %s\n", stat.ModTime().Format(sqliteTimestampFormat))
+		} else {
+			fmt.Printf("File:          not readable (%v)\n", err)
+		}
+
+		fmt.Printf("Characters:    %d\n", info.Characters)
+		fmt.Printf("Empty pages:   %d\n", info.EmptyPages)
+		if looksImageOnly(info.Pages, info.Characters) {
+			fmt.Println("Image-only:    probably, little or no text could be extracted")
+		} else {
+			fmt.Println("Image-only:    no")
+		}
+
+		if len(info.LowQualityPages) > 0 {
+			fmt.Printf("Low quality:   %d page(s) look garbled, consider OCR or re-processing: %s\n",
+				len(info.LowQualityPages), formatPageList(info.LowQualityPages))
+		}
+
+		if info.PageOffset != 0 {
+			fmt.Printf("Page offset:   %d (pages numbered %s to %s)\n", info.PageOffset,
+				database.PrintedPage(1, info.PageOffset), database.PrintedPage(info.Pages, info.PageOffset))
+		}
+
+		if info.Volume != nil {
+			fmt.Printf("Volume:        %d of %s (from document page %d)\n",
+				info.Volume.Number, info.Volume.Document, info.Volume.DocumentPage(1))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+	addDatabaseFlag(infoCmd)
+}
+
+// maxListedPages is the number of page numbers printed before eliding the rest
+const maxListedPages = 20
+
+// formatPageList joins page numbers with commas, eliding long lists
+func formatPageList(pages []int) string {
+	parts := make([]string, 0, min(len(pages), maxListedPages))
+	for _, page := range pages[:min(len(pages), maxListedPages)] {
+		parts = append(parts, strconv.Itoa(page))
+	}
+	list := strings.Join(parts, ", ")
+	if len(pages) > maxListedPages {
+		list += fmt.Sprintf(", ... (%d more)", len(pages)-maxListedPages)
+	}
+	return list
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// likeCandidates is the number of most frequent words of the document
+	// whose corpus frequency is looked up
+	likeCandidates = 40
+	// likeTerms is the number of distinctive words in the --like query
+	likeTerms = 10
+	// likeMinWordLength skips short words, which are rarely distinctive
+	likeMinWordLength = 4
+)
+
+// likeQuery builds a MATCH expression finding documents similar to the given
+// indexed one: its most distinctive words, frequent in it and rare in the
+// other documents (tf-idf), joined with OR so that pages sharing more of them
+// rank higher. This is a heuristic based on shared words, not on meaning.
+func likeQuery(path string) (string, error) {
+	pages, err := db.GetPageContent(path, 1, math.MaxInt32)
+	if err != nil {
+		return "", err
+	}
+	if len(pages) == 0 {
+		return "", fmt.Errorf("%s is not indexed, paths are stored as given to 'scan'", path)
+	}
+
+	counts := make(map[string]int)
+	for _, page := range pages {
+		words := strings.FieldsFunc(strings.ToLower(page.Content), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if len([]rune(word)) >= likeMinWordLength && strings.IndexFunc(word, unicode.IsLetter) >= 0 {
+				counts[word]++
+			}
+		}
+	}
+
+	type term struct {
+		word  string
+		count int
+		score float64
+	}
+	var terms []term
+	for word, count := range counts {
+		terms = append(terms, term{word: word, count: count})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].count != terms[j].count {
+			return terms[i].count > terms[j].count
+		}
+		return terms[i].word < terms[j].word
+	})
+	terms = terms[:min(len(terms), likeCandidates)]
+
+	documents, err := db.DocumentCount()
+	if err != nil {
+		return "", err
+	}
+
+	// Words found in every document don't tell documents apart
+	var distinctive []term
+	for _, t := range terms {
+		_, docs, err := db.CountMatches(quoteFTSTerm(t.word))
+		if err != nil {
+			return "", err
+		}
+		if docs == 0 || docs >= documents {
+			continue
+		}
+		t.score = float64(t.count) * math.Log(float64(documents)/float64(docs))
+		distinctive = append(distinctive, t)
+	}
+	if len(distinctive) == 0 {
+		return "", fmt.Errorf("no distinctive words found in %s", path)
+	}
+
+	sort.SliceStable(distinctive, func(i, j int) bool {
+		return distinctive[i].score > distinctive[j].score
+	})
+	distinctive = distinctive[:min(len(distinctive), likeTerms)]
+
+	quoted := make([]string, len(distinctive))
+	for i, t := range distinctive {
+		quoted[i] = quoteFTSTerm(t.word)
+		log.Printf("Like term %q: %d occurrences, score %.2f", t.word, t.count, t.score)
+	}
+	return strings.Join(quoted, " OR "), nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the indexed documents",
+	Long: util.Dedent(`
+		List the indexed documents with their page count and the number of
+		characters extracted from them. Sort by size to find the largest
+		documents, an unusually large extraction often means garbled text, or
+		by pages to find the longest ones. Documents with pages without any
+		text, like blank or scanned pages, also show how many have text.
+
+		With --json the documents are printed as a JSON array for scripts,
+		with their hash, last scan time and the size and modification time
+		of the file when it exists. --fields keeps only some keys.
+	`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		order, _ := cmd.Flags().GetString("sort")
+		switch order {
+		case database.ListByPath, database.ListBySize, database.ListByPages:
+		default:
+			return fmt.Errorf("invalid --sort %q, expected path, size or pages", order)
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		fields, _ := cmd.Flags().GetStringSlice("fields")
+		if len(fields) > 0 && !asJSON {
+			return fmt.Errorf("--fields requires --json")
+		}
+		if err := validateOutputFields(fields, listJSONFields); err != nil {
+			return err
+		}
+
+		documents, err := db.ListDocuments(order, limit)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printDocumentsJSON(documents, fields)
+		}
+		if len(documents) == 0 {
+			fmt.Println("No documents indexed. Run 'scan' to index some files.")
+			return nil
+		}
+
+		// The text pages column is only shown when some document has pages
+		// without text
+		textPages := false
+		for _, doc := range documents {
+			textPages = textPages || doc.TextPages != doc.Pages
+		}
+
+		for _, doc := range documents {
+			if !textPages {
+				fmt.Printf("%6d pages %10d chars  %s\n", doc.Pages, doc.Characters, doc.Path)
+				continue
+			}
+			withText := ""
+			if doc.TextPages != doc.Pages {
+				withText = fmt.Sprintf("(%d with text)", doc.TextPages)
+			}
+			fmt.Printf("%6d pages %-17s %10d chars  %s\n", doc.Pages, withText, doc.Characters, doc.Path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	addDatabaseFlag(listCmd)
+	listCmd.Flags().String("sort", database.ListByPath, "document order: path, size for the most extracted text first or pages for the longest documents first")
+	listCmd.Flags().IntP("limit", "l", 0, "maximum number of documents, 0 for no limit")
+	listCmd.Flags().Bool("json", false, "print the documents as a JSON array")
+	listCmd.Flags().StringSlice("fields", nil, "only include these comma separated fields in the JSON output (fields: "+strings.Join(listJSONFields, ", ")+")")
+}
+
+// jsonDocument is an indexed document in the list --json output
+type jsonDocument struct {
+	Path        string `json:"path"`
+	Pages       int    `json:"pages"`
+	TextPages   int    `json:"text_pages"`
+	Characters  int    `json:"characters"`
+	Hash        string `json:"hash"`
+	LastScanned string `json:"last_scanned"`
+	// The file fields are omitted when the file can't be read
+	FileSize *int64 `json:"file_size,omitempty"`
+	Modified string `json:"modified,omitempty"`
+}
+
+// listJSONFields are the keys of a JSON document that can be selected with --fields
+var listJSONFields = []string{"path", "pages", "text_pages", "characters", "hash", "last_scanned", "file_size", "modified"}
+
+// printDocumentsJSON prints the documents as a JSON array, an empty one when
+// nothing is indexed
+func printDocumentsJSON(documents []database.DocumentSummary, fields []string) error {
+	output := make([]any, 0, len(documents))
+	for _, doc := range documents {
+		jd := jsonDocument{
+			Path:        doc.Path,
+			Pages:       doc.Pages,
+			TextPages:   doc.TextPages,
+			Characters:  doc.Characters,
+			Hash:        doc.Hash,
+			LastScanned: doc.LastScanned,
+		}
+		if stat, err := os.Stat(doc.Path); err == nil {
+			size := stat.Size()
+			jd.FileSize = &size
+			jd.Modified = stat.ModTime().Format(sqliteTimestampFormat)
+		}
+
+		selected, err := selectFields(jd, fields)
+		if err != nil {
+			return err
+		}
+		output = append(output, selected)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"time"
+
+	"github.com/aziis98/pdf-fts/internal/database"
 	"github.com/aziis98/pdf-fts/internal/ui"
 	"github.com/aziis98/pdf-fts/internal/util"
 	tea "github.com/charmbracelet/bubbletea"
@@ -22,10 +25,19 @@ var liveCmd = &cobra.Command{
 		}
 
 		uiHandler := ui.New(db, cfg.Verbose)
+		uiHandler.RelativePaths, _ = cmd.Flags().GetBool("relative")
+		uiHandler.MatchQuery = defaultMatchQuery
+		cacheSize, _ := cmd.Flags().GetInt("cache-size")
+		cacheTTL, _ := cmd.Flags().GetDuration("cache-ttl")
+		uiHandler.Cache = database.NewSearchCache(db, cacheSize, cacheTTL)
 		return uiHandler.HandleLiveSearchCommand()
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(liveCmd)
+	addReadOnlyFallbackFlag(liveCmd)
+	liveCmd.Flags().Bool("relative", false, "show paths relative to the current directory")
+	liveCmd.Flags().Int("cache-size", 64, "number of recent queries whose results are cached, 0 disables the cache")
+	liveCmd.Flags().Duration("cache-ttl", time.Minute, "how long cached results are reused, they are also dropped after a scan")
 }
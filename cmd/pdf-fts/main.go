@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 )
 
+// version is set at build time for releases with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.Execute(); err != nil && !errors.Is(err, errDBInfoPrinted) {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
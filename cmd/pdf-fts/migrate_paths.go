@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var migratePathsCmd = &cobra.Command{
+	Use:   "migrate-paths",
+	Short: "Rewrite stored paths after moving the indexed files",
+	Long: util.Dedent(`
+		Replace the directory prefix of the stored paths, e.g. after moving a
+		library, so the extracted text is kept without scanning everything again.
+		Reports the rewritten files that don't exist at their new path.
+	`),
+	Example: "  pdf-fts migrate-paths --from /mnt/old/library --to /home/me/library",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		if from == "" || to == "" {
+			return fmt.Errorf("both --from and --to are required")
+		}
+
+		count, err := db.RewritePathPrefix(from, to)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rewrote %d file path(s).\n", count)
+
+		paths, err := db.IndexedPaths(to)
+		if err != nil {
+			return err
+		}
+		missing := 0
+		for _, path := range paths {
+			if _, err := os.Stat(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s not found on disk\n", path)
+				missing++
+			}
+		}
+		if missing > 0 {
+			fmt.Printf("%d file(s) under %s don't exist on disk.\n", missing, to)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migratePathsCmd)
+	migratePathsCmd.Flags().String("from", "", "old path prefix to replace")
+	migratePathsCmd.Flags().String("to", "", "new path prefix")
+}
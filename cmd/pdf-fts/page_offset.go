@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var pageOffsetCmd = &cobra.Command{
+	Use:   "page-offset <path> [offset]",
+	Short: "Set how the pages of a document are numbered",
+	Long: util.Dedent(`
+		Set the number of pages before page 1 of the numbering printed in an
+		indexed file, like its cover and front matter, so search results show
+		the pages as numbered in the document. With an offset of 14, PDF page 15
+		is shown as "p.1" and the previous pages as "p.i" to "p.xiv". The offset
+		must be smaller than the number of pages of the file, and an offset of 0
+		restores the PDF page numbers. Without an offset this prints the current
+		one.
+
+		Only the displayed numbers change: the viewer, export-page and the plain
+		and CSV outputs keep using PDF pages, and JSON results get a
+		"printed_page" field.
+	`),
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := filepath.Clean(args[0])
+
+		if len(args) == 1 {
+			offset, err := db.PageOffset(path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: page offset %d\n", path, offset)
+			return nil
+		}
+
+		offset, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid page offset %q, expected a number of pages", args[1])
+		}
+		if err := db.SetPageOffset(path, offset); err != nil {
+			return err
+		}
+		if offset == 0 {
+			fmt.Printf("Removed the page offset of %s.\n", path)
+		} else {
+			fmt.Printf("PDF page %d of %s is now shown as page %s.\n", max(offset+1, 1), path,
+				database.PrintedPage(max(offset+1, 1), offset))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pageOffsetCmd)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// Progress output modes selectable with --progress
+const (
+	progressBar  = "bar"
+	progressJSON = "json"
+	progressNone = "none"
+)
+
+// progressReporter reports the advancement of a long-running phase
+type progressReporter interface {
+	// Step marks one more item as done, path is the item just processed
+	Step(path string)
+	// Finish is called once the phase is complete
+	Finish()
+}
+
+// newProgress creates a progress reporter for a phase according to the
+// selected progress mode. The human progress bar is disabled in verbose mode
+// as it would be interleaved with the log output.
+func newProgress(phase, description string, total int) progressReporter {
+	switch progressMode {
+	case progressJSON:
+		return &jsonProgress{
+			encoder: json.NewEncoder(progressWriter()),
+			phase:   phase,
+			total:   total,
+		}
+	case progressBar:
+		if !cfg.Verbose {
+			return &barProgress{bar: newProgressBar(description, total)}
+		}
+	}
+	return noProgress{}
+}
+
+// progressWriter returns the stream selected with --progress-output
+func progressWriter() io.Writer {
+	if progressOutput == "stdout" {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// validateProgressFlags checks the values of the progress flags
+func validateProgressFlags() error {
+	switch progressMode {
+	case progressBar, progressJSON, progressNone:
+	default:
+		return fmt.Errorf("invalid --progress %q, expected one of: bar, json, none", progressMode)
+	}
+	switch progressOutput {
+	case "stdout", "stderr":
+	default:
+		return fmt.Errorf("invalid --progress-output %q, expected stdout or stderr", progressOutput)
+	}
+	return nil
+}
+
+// newProgressBar creates the progress bar used by all commands
+func newProgressBar(description string, total int) *progressbar.ProgressBar {
+	return progressbar.NewOptions(total,
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetWidth(50),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "=",
+			SaucerHead:    ">",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}))
+}
+
+// barProgress renders an interactive progress bar
+type barProgress struct {
+	bar *progressbar.ProgressBar
+}
+
+func (p *barProgress) Step(path string) {
+	p.bar.Add(1)
+}
+
+func (p *barProgress) Finish() {
+	fmt.Println() // New line after progress bar
+}
+
+// jsonProgress writes newline-delimited JSON progress records
+type jsonProgress struct {
+	encoder *json.Encoder
+	phase   string
+	done    int
+	total   int
+}
+
+// progressRecord is a single JSON progress record
+type progressRecord struct {
+	Phase string `json:"phase"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	Path  string `json:"path,omitempty"`
+}
+
+func (p *jsonProgress) Step(path string) {
+	p.done++
+	p.encoder.Encode(progressRecord{Phase: p.phase, Done: p.done, Total: p.total, Path: path})
+}
+
+func (p *jsonProgress) Finish() {}
+
+// noProgress discards all progress updates
+type noProgress struct{}
+
+func (noProgress) Step(path string) {}
+func (noProgress) Finish()          {}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readQueryFile reads the queries of a --query-file, one per line, skipping
+// blank lines and comments starting with #
+func readQueryFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening query file: %w", err)
+	}
+	defer file.Close()
+
+	var queries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading query file: %w", err)
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%s has no queries", path)
+	}
+	return queries, nil
+}
+
+// runQueryFile runs a search for each query of opts.QueryFile with the same
+// options. Text output gets a header before the results of each query, CSV a
+// query column and JSON lines a "query" key. A failing query is reported and
+// the next ones still run.
+func runQueryFile(opts searchOptions) error {
+	queries, err := readQueryFile(opts.QueryFile)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i, query := range queries {
+		opts.BatchQuery = query
+		opts.BatchIndex = i
+
+		if !opts.JSONLines && opts.Format == formatText {
+			if i > 0 {
+				fmt.Println()
+			}
+			// The grouped output has its own header
+			if opts.Plain || opts.Oneline {
+				fmt.Printf("# %s\n", query)
+			}
+		}
+
+		if err := runSearchCommand(query, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: query %q failed: %v\n", query, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d queries failed", failed, len(queries))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var rankWeightsCmd = &cobra.Command{
+	Use:   "rank-weights [filename-weight content-weight]",
+	Short: "Set the default ranking weights of the database",
+	Long: util.Dedent(`
+		Set the bm25 weights of matches in the file name and in the page content
+		used by the searches of this database that don't pass --filename-weight
+		or --content-weight, including the live search. They are also set as
+		the rank of the FTS index, so they survive 'rebuild-fts' and apply to
+		any query ordering by rank. Without arguments this prints the current
+		weights, --reset restores the default ones.
+	`),
+	Args: cobra.MatchAll(cobra.RangeArgs(0, 2), func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			return fmt.Errorf("expected both the filename and the content weight")
+		}
+		return nil
+	}),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reset, _ := cmd.Flags().GetBool("reset"); reset {
+			if len(args) > 0 {
+				return fmt.Errorf("--reset takes no weights")
+			}
+			if err := db.ResetRankWeights(); err != nil {
+				return err
+			}
+			fmt.Println("Restored the default rank weights.")
+			return nil
+		}
+
+		if len(args) == 0 {
+			filename, content, set, err := db.RankWeights()
+			if err != nil {
+				return err
+			}
+			source := "default"
+			if set {
+				source = "set with rank-weights"
+			}
+			fmt.Printf("Filename weight: %g\nContent weight:  %g\n(%s)\n", filename, content, source)
+			return nil
+		}
+
+		weights := make([]float64, 2)
+		for i, arg := range args {
+			weight, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Errorf("invalid weight %q, expected a number", arg)
+			}
+			weights[i] = weight
+		}
+		if err := db.SetRankWeights(weights[0], weights[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Rank weights set to %g for the file name and %g for the content.\n", weights[0], weights[1])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rankWeightsCmd)
+	addDatabaseFlag(rankWeightsCmd)
+	rankWeightsCmd.Flags().Bool("reset", false, "restore the default weights")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var readCmd = &cobra.Command{
+	Use:   "read [files...]",
+	Short: "Mark documents as read or list the read ones",
+	Long: util.Dedent(`
+		Mark the given indexed files as read, or as unread with --unset. Marks are
+		kept by path and survive re-scans. Without arguments this lists the
+		documents marked as read. Use "search --unread" to only search the
+		documents not read yet.
+	`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		unset, _ := cmd.Flags().GetBool("unset")
+
+		if len(args) == 0 {
+			if unset {
+				return fmt.Errorf("--unset requires the files to mark as unread")
+			}
+			return listReadDocuments()
+		}
+
+		paths := make([]string, len(args))
+		for i, arg := range args {
+			paths[i] = filepath.Clean(arg)
+		}
+		if err := db.MarkRead(paths, !unset); err != nil {
+			return err
+		}
+		if unset {
+			fmt.Printf("Marked %d file(s) as unread.\n", len(paths))
+		} else {
+			fmt.Printf("Marked %d file(s) as read.\n", len(paths))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(readCmd)
+	readCmd.Flags().Bool("unset", false, "mark the given files as unread")
+}
+
+// listReadDocuments prints the documents marked as read, most recent first
+func listReadDocuments() error {
+	documents, err := db.ReadDocuments()
+	if err != nil {
+		return err
+	}
+	if len(documents) == 0 {
+		fmt.Println("No documents marked as read.")
+		return nil
+	}
+
+	for _, document := range documents {
+		fmt.Printf("%s  %s\n", document.ReadAt.Local().Format("2006-01-02 15:04"), document.Path)
+	}
+	return nil
+}
@@ -15,11 +15,37 @@ var rebuildFtsCmd = &cobra.Command{
 		This can help improve search performance and fix any index corruption issues.
 	`),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		total, err := db.PageCount()
+		if err != nil {
+			return err
+		}
+		if total == 0 {
+			fmt.Println("The database is empty, nothing to rebuild. Run 'scan' to index some files.")
+			return nil
+		}
+
 		fmt.Println("Rebuilding Full-Text Search index...")
-		return db.RebuildFTS()
+		progress := newProgress("rebuilding", "Rebuilding index", total)
+		count, err := db.RebuildFTS(progress.Step)
+		progress.Finish()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Repopulated %d page(s).\n", count)
+
+		if verify, _ := cmd.Flags().GetBool("verify"); verify {
+			fmt.Println("Verifying the index...")
+			if err := db.VerifyFTS(); err != nil {
+				return fmt.Errorf("verifying the rebuilt index: %w", err)
+			}
+			fmt.Println("The index is consistent with the stored pages.")
+		}
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(rebuildFtsCmd)
+	rebuildFtsCmd.Flags().Bool("verify", false, "check the rebuilt index with the FTS5 integrity check and against the stored pages")
 }
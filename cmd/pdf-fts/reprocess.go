@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aziis98/pdf-fts/internal/pdf"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var reprocessCmd = &cobra.Command{
+	Use:   "reprocess [files...]",
+	Short: "Clean the stored raw text again without reading the PDFs",
+	Long: util.Dedent(`
+		Rebuild the indexed text of documents scanned with --store-raw from their
+		stored raw text, applying the cleaning and normalization of this version
+		and the page filters given as flags. This is much faster than extracting
+		the PDFs again with 'scan --force'. Without arguments every document with
+		raw text is reprocessed.
+	`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts scanOptions
+		opts.StripBoilerplate, _ = cmd.Flags().GetBool("strip-boilerplate")
+		opts.BoilerplateThreshold, _ = cmd.Flags().GetFloat64("boilerplate-threshold")
+		opts.CollapseDuplicates, _ = cmd.Flags().GetBool("collapse-duplicate-pages")
+		indexEmpty, _ := cmd.Flags().GetBool("index-empty-pages")
+		opts.SkipEmptyPages = !indexEmpty
+		opts.MaxSegmentChars, _ = cmd.Flags().GetInt("max-segment-chars")
+		opts.Chunk, _ = cmd.Flags().GetString("chunk")
+		opts.Wait, _ = cmd.Flags().GetBool("wait")
+		opts.TextFilters, _ = cmd.Flags().GetStringSlice("text-filters")
+		if _, err := pdf.LookupFilters(opts.TextFilters); err != nil {
+			return err
+		}
+
+		paths := make([]string, len(args))
+		for i, arg := range args {
+			paths[i] = filepath.Clean(arg)
+		}
+		return runReprocessCommand(paths, opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reprocessCmd)
+	reprocessCmd.Flags().Bool("wait", false, "wait for a running scan to finish instead of failing")
+	addTextFiltersFlag(reprocessCmd)
+	reprocessCmd.Flags().Bool("strip-boilerplate", false, "remove running headers and footers repeated across pages")
+	reprocessCmd.Flags().Float64("boilerplate-threshold", 0.5, "fraction of pages a line must appear on to be removed by --strip-boilerplate")
+	reprocessCmd.Flags().Bool("collapse-duplicate-pages", false, "index only the first of the pages of a document with identical content")
+	reprocessCmd.Flags().Bool("index-empty-pages", true, "store the pages without any text, set to false to keep them out of the index")
+	reprocessCmd.Flags().Int("max-segment-chars", 0, "split pages longer than this many characters into separately ranked segments (0 disables)")
+	addChunkFlag(reprocessCmd)
+}
+
+func runReprocessCommand(paths []string, opts scanOptions) error {
+	// Reprocessing rewrites pages like a scan does
+	lock, err := acquireScanLock(opts.Wait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if err := resolveChunkMode(&opts); err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		paths, err = db.RawPaths()
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			fmt.Println("No raw text stored, scan with --store-raw first.")
+			return nil
+		}
+	}
+
+	pdfProcessor := pdf.New(cfg.Verbose)
+	if pdfProcessor.Filters, err = pdf.LookupFilters(opts.TextFilters); err != nil {
+		return err
+	}
+	progress := newProgress("reprocessing", "Reprocessing", len(paths))
+
+	reprocessed, failed := 0, 0
+	for _, path := range paths {
+		if err := reprocessDocument(pdfProcessor, path, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to reprocess %s: %v\n", path, err)
+			failed++
+		} else {
+			reprocessed++
+		}
+		progress.Step(path)
+	}
+	progress.Finish()
+
+	if reprocessed > 0 {
+		if err := db.BumpGeneration(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to invalidate cached searches: %v\n", err)
+		}
+	}
+	fmt.Printf("Reprocessed %d document(s).\n", reprocessed)
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d document(s) could not be reprocessed.\n", failed)
+	}
+	return nil
+}
+
+// reprocessDocument replaces the indexed pages of a document with its stored
+// raw text cleaned again, keeping the stored hash
+func reprocessDocument(pdfProcessor *pdf.Extractor, path string, opts scanOptions) error {
+	raw, err := db.RawPages(path)
+	if err != nil {
+		return err
+	}
+	if raw == nil {
+		return fmt.Errorf("no raw text stored, scan it with --store-raw")
+	}
+
+	hash, err := db.GetStoredHash(path)
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		return fmt.Errorf("not fully indexed, scan it again")
+	}
+
+	pages := make([]pdf.Page, len(raw))
+	for i, page := range raw {
+		pages[i] = pdfProcessor.PageFromRaw(page.Text, page.Annotations)
+	}
+	pages = filterPages(pdfProcessor, path, pages, opts)
+
+	return db.UpsertPDFData(path, hash, toDBPages(pages), 0)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/pdf"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var reprocessFailedCmd = &cobra.Command{
+	Use:   "reprocess-failed",
+	Short: "Extract again the documents that came out without text",
+	Long: util.Dedent(`
+		Extract again from the PDFs only the indexed documents where little or
+		no text could be extracted, the ones 'info' reports as image-only, e.g.
+		after installing a better MuPDF. Then report how many now have text and
+		how many still don't. Files that failed to extract entirely are not
+		indexed and are already retried by every scan.
+
+		The documents are indexed with the extraction flags given here, pass
+		the ones they were scanned with (e.g. --strip-boilerplate or
+		--index-annotations). Raw text stored with 'scan --store-raw' is kept.
+	`),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := defaultScanOptions()
+		opts.Force = true
+		opts.History = false
+		opts.Wait, _ = cmd.Flags().GetBool("wait")
+		opts.ExtractTimeout, _ = cmd.Flags().GetDuration("extract-timeout")
+		opts.StripBoilerplate, _ = cmd.Flags().GetBool("strip-boilerplate")
+		opts.BoilerplateThreshold, _ = cmd.Flags().GetFloat64("boilerplate-threshold")
+		opts.IndexAnnotations, _ = cmd.Flags().GetBool("index-annotations")
+		opts.StoreRaw, _ = cmd.Flags().GetBool("store-raw")
+		opts.CollapseDuplicates, _ = cmd.Flags().GetBool("collapse-duplicate-pages")
+		indexEmpty, _ := cmd.Flags().GetBool("index-empty-pages")
+		opts.SkipEmptyPages = !indexEmpty
+		opts.MaxSegmentChars, _ = cmd.Flags().GetInt("max-segment-chars")
+		opts.Chunk, _ = cmd.Flags().GetString("chunk")
+		opts.TextFilters, _ = cmd.Flags().GetStringSlice("text-filters")
+		if _, err := pdf.LookupFilters(opts.TextFilters); err != nil {
+			return err
+		}
+		return reprocessFailed(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reprocessFailedCmd)
+	addDatabaseFlag(reprocessFailedCmd)
+	reprocessFailedCmd.Flags().Bool("wait", false, "wait for another running scan to finish instead of failing")
+	reprocessFailedCmd.Flags().Duration("extract-timeout", 0, "skip files whose text extraction takes longer than this (e.g. 30s, 0 disables)")
+	reprocessFailedCmd.Flags().Bool("strip-boilerplate", false, "remove running headers and footers repeated across pages")
+	reprocessFailedCmd.Flags().Float64("boilerplate-threshold", 0.5, "fraction of pages a line must appear on to be removed by --strip-boilerplate")
+	reprocessFailedCmd.Flags().Bool("store-raw", false, "also store the text before cleaning, so 'reprocess' can apply new cleaning rules without the PDFs")
+	addTextFiltersFlag(reprocessFailedCmd)
+	reprocessFailedCmd.Flags().Bool("index-annotations", false, "also index the page annotations (currently link targets only)")
+	reprocessFailedCmd.Flags().Bool("collapse-duplicate-pages", false, "index only the first of the pages of a document with identical content")
+	reprocessFailedCmd.Flags().Bool("index-empty-pages", true, "store the pages without any text, set to false to keep them out of the index")
+	reprocessFailedCmd.Flags().Int("max-segment-chars", 0, "split pages longer than this many characters into separately ranked segments (0 disables)")
+	addChunkFlag(reprocessFailedCmd)
+}
+
+// reprocessFailed rescans the image-only documents that still exist and
+// reports how many of them were fixed
+func reprocessFailed(opts scanOptions) error {
+	documents, err := db.ListDocuments(database.ListByPath, 0)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for _, doc := range documents {
+		if !looksImageOnly(doc.Pages, doc.Characters) {
+			continue
+		}
+		if _, err := os.Stat(doc.Path); errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping %s, the file no longer exists\n", doc.Path)
+			continue
+		}
+		paths = append(paths, doc.Path)
+	}
+	if len(paths) == 0 {
+		fmt.Println("No documents without text to reprocess.")
+		return nil
+	}
+
+	fmt.Printf("Reprocessing %d document(s) without text...\n\n", len(paths))
+	stats, err := scanFolders(paths, opts)
+	if err != nil {
+		return err
+	}
+
+	// Files that failed to extract or store keep their previous pages
+	var failing []string
+	for _, path := range paths {
+		if !slices.Contains(stats.UpdatedPaths, path) {
+			failing = append(failing, path)
+			continue
+		}
+		info, err := db.DocumentInfo(path, 0)
+		if err != nil {
+			return err
+		}
+		if info == nil || looksImageOnly(info.Pages, info.Characters) {
+			failing = append(failing, path)
+		}
+	}
+
+	if len(failing) > 0 {
+		fmt.Printf("\nStill without text (%d):\n", len(failing))
+		for _, path := range failing {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	fmt.Printf("\n%d document(s) fixed, %d still without text.\n", len(paths)-len(failing), len(failing))
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var resetCmd = &cobra.Command{
+	Use:     "reset",
+	Aliases: []string{"clear"},
+	Short:   "Remove everything from the index",
+	Long: util.Dedent(`
+		Drop all the indexed documents, the extraction cache, volumes and read
+		status, and recreate an empty database with the current schema. This
+		can't be undone, pass --yes to confirm.
+	`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+			return fmt.Errorf("this removes everything from %s, pass --yes to confirm", cfg.DBPath)
+		}
+
+		count, err := db.Reset()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d document(s), the index is now empty.\n", count)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resetCmd)
+	resetCmd.Flags().Bool("yes", false, "confirm removing everything from the index")
+}
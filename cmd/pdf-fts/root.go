@@ -5,6 +5,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 
 	"github.com/aziis98/pdf-fts/internal/config"
 	"github.com/aziis98/pdf-fts/internal/database"
@@ -14,15 +16,22 @@ import (
 )
 
 var (
-	cfg     *config.Config
-	db      *database.DB
-	verbose bool
+	cfg              *config.Config
+	db               *database.DB
+	verbose          bool
+	dbBoundary       []string
+	skipVersionCheck bool
+	checkIntegrity   bool
+	progressMode     string
+	progressOutput   string
+	dbOptions        = database.DefaultOptions()
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "pdf-fts",
-	Short: "PDF Full-Text Search Tool",
+	Use:     "pdf-fts",
+	Version: version,
+	Short:   "PDF Full-Text Search Tool",
 	Long: util.Dedent(`
 		A powerful tool for indexing and searching text content within PDF files.
 		It extracts text from PDFs, stores it in a sqlite database with fts5 support,
@@ -32,6 +41,17 @@ var rootCmd = &cobra.Command{
 		// Initialize configuration
 		cfg = config.New()
 		cfg.Verbose = verbose
+		cfg.BoundaryMarkers = dbBoundary
+
+		if err := validateProgressFlags(); err != nil {
+			return err
+		}
+		if err := setupColor(); err != nil {
+			return err
+		}
+		if err := validateDBFlags(); err != nil {
+			return err
+		}
 
 		// Setup logging
 		if cfg.Verbose {
@@ -42,15 +62,39 @@ var rootCmd = &cobra.Command{
 			log.SetOutput(io.Discard)
 		}
 
+		if dbInfo {
+			if err := printDBInfo(cmd); err != nil {
+				return err
+			}
+			// Stops before running the command, main exits successfully
+			cmd.SilenceErrors, cmd.SilenceUsage = true, true
+			return errDBInfoPrinted
+		}
+
+		// An explicit --database replaces the discovery for this invocation
+		if flag := cmd.Flags().Lookup("database"); flag != nil && flag.Value.String() != "" {
+			return openExplicitDB(cmd.Name(), flag.Value.String())
+		}
+		// The first --db of a federated search is opened as the main database
+		if paths, err := cmd.Flags().GetStringArray("db"); err == nil && len(paths) > 0 {
+			return openExplicitDB(cmd.Name(), paths[0])
+		}
+
 		// Find or create database path based on command
 		cmdName := cmd.Name()
-		switch cmdName {
-		case "scan":
+		switch {
+		case cmdName == "selftest":
+			// Uses its own temporary database
+			return nil
+		case cmdName == "export-page":
+			// Reads the PDF directly
+			return nil
+		case cmdName == "scan":
 			// Scan can create a new database if none exists
 			if err := cfg.FindOrCreateDBPath(); err != nil {
 				return fmt.Errorf("finding or creating database path: %w", err)
 			}
-		case "search", "live", "rebuild-fts":
+		case requiresExistingDB(cmdName):
 			// These commands require an existing database
 			if err := cfg.FindExistingDBPath(); err != nil {
 				return fmt.Errorf("no database found - please run 'scan' first to create and populate the database")
@@ -68,11 +112,25 @@ var rootCmd = &cobra.Command{
 
 		// Initialize database
 		var err error
-		db, err = database.New(cfg.DBPath, cfg.Verbose)
+		db, err = database.New(cfg.DBPath, databaseOptions())
+		if err != nil && database.IsBusy(err) && readOnlyFallback(cmd) {
+			// The read-only database isn't migrated, NewReadOnly refuses an older layout
+			if db, err = database.NewReadOnly(cfg.DBPath, databaseOptions()); err != nil {
+				return fmt.Errorf("the database is busy (is a scan running?) and can't be opened read-only: %w", err)
+			}
+			fmt.Fprintln(os.Stderr, "Warning: the database is busy (is a scan running?), opening it read-only, results may be slightly stale")
+		}
 		if err != nil {
 			return fmt.Errorf("initializing database: %w", err)
 		}
 
+		if !skipVersionCheck {
+			checkSchemaVersion()
+		}
+		if checkIntegrity {
+			checkIndexIntegrity()
+		}
+
 		return nil
 	},
 	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
@@ -83,7 +141,159 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// existingDBCommands are the commands failing when no database is found
+var existingDBCommands = []string{
+	"search", "count", "live", "rebuild-fts", "clear-cache", "volumes", "info", "migrate-paths",
+	"read", "reprocess", "reset", "list", "history", "verify-hashes", "page-offset", "reprocess-failed", "rank-weights",
+}
+
+// writeDBCommands are the commands opening the database of --database
+// read-write, other commands open it read-only
+var writeDBCommands = []string{"scan", "reprocess-failed", "rank-weights"}
+
+// requiresExistingDB reports whether a command fails when no database is found
+func requiresExistingDB(cmdName string) bool {
+	return slices.Contains(existingDBCommands, cmdName)
+}
+
+// openExplicitDB opens the database given with a command's --database flag.
+// Only writeDBCommands may write to it and only scan creates it, other
+// commands open it read-only.
+// An in-memory database starts empty and is discarded when the command ends.
+func openExplicitDB(cmdName, dbPath string) error {
+	if database.IsMemory(dbPath) {
+		cfg.DBPath = dbPath
+		var err error
+		if db, err = database.New(dbPath, databaseOptions()); err != nil {
+			return fmt.Errorf("initializing database: %w", err)
+		}
+		return nil
+	}
+
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return fmt.Errorf("resolving database path %s: %w", dbPath, err)
+	}
+	cfg.DBPath = absPath
+
+	if cfg.Verbose {
+		log.Printf("Using database at: %s", cfg.DBPath)
+	}
+
+	readOnly := !slices.Contains(writeDBCommands, cmdName)
+	if _, err := os.Stat(absPath); err == nil {
+		// NewReadOnly also checks the layout version
+		if !readOnly {
+			if err := database.ValidateMigratable(absPath); err != nil {
+				return err
+			}
+		}
+	} else if cmdName != "scan" {
+		return fmt.Errorf("database %s not found: %w", dbPath, err)
+	}
+
+	if readOnly {
+		db, err = database.NewReadOnly(absPath, databaseOptions())
+	} else {
+		db, err = database.New(absPath, databaseOptions())
+	}
+	if err != nil {
+		return fmt.Errorf("initializing database: %w", err)
+	}
+
+	if !skipVersionCheck {
+		checkSchemaVersion()
+	}
+	if checkIntegrity {
+		checkIndexIntegrity()
+	}
+	return nil
+}
+
+// validateDBFlags checks the global --db-* flags not validated when opening
+// the database
+func validateDBFlags() error {
+	// Some commands query the database while reading the results of another query
+	if dbOptions.MaxOpenConns != 0 && dbOptions.MaxOpenConns < 2 {
+		return fmt.Errorf("invalid --db-max-open-conns %d, expected 0 for no limit or at least 2", dbOptions.MaxOpenConns)
+	}
+	return nil
+}
+
+// databaseOptions returns the connection options set by the global --db-* flags
+func databaseOptions() database.Options {
+	opts := dbOptions
+	opts.Verbose = cfg.Verbose
+	return opts
+}
+
+// addDatabaseFlag adds the --database flag selecting the database for a single command
+func addDatabaseFlag(cmd *cobra.Command) {
+	cmd.Flags().String("database", "", "use this database file instead of looking for fts.db, or :memory: for a throwaway one")
+}
+
+// addReadOnlyFallbackFlag adds the --db-readonly-fallback flag to a command
+// that only reads the database
+func addReadOnlyFallbackFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("db-readonly-fallback", true, "open the database read-only when another process (e.g. a scan) keeps it busy")
+}
+
+// readOnlyFallback reports whether the command may open a busy database read-only
+func readOnlyFallback(cmd *cobra.Command) bool {
+	fallback, err := cmd.Flags().GetBool("db-readonly-fallback")
+	return err == nil && fallback
+}
+
+// checkSchemaVersion warns when the database was indexed by a build with a
+// different schema or normalization than the running one
+func checkSchemaVersion() {
+	stored, err := db.StoredSchemaVersion()
+	if err != nil {
+		log.Printf("Warning: Could not check database version: %v", err)
+		return
+	}
+
+	switch {
+	case stored < database.SchemaVersion:
+		fmt.Fprintf(os.Stderr,
+			"Warning: the database was indexed by an older version of pdf-fts (schema %d, current %d), "+
+				"run 'pdf-fts scan --force' to re-index your files\n",
+			stored, database.SchemaVersion)
+	case stored > database.SchemaVersion:
+		toolVersion, _ := db.GetMeta("tool_version")
+		fmt.Fprintf(os.Stderr,
+			"Warning: the database was indexed by a newer version of pdf-fts (%s, schema %d, current %d), "+
+				"consider upgrading\n",
+			toolVersion, stored, database.SchemaVersion)
+	}
+}
+
+// checkIndexIntegrity prints a one-line summary of CheckFTS on stderr, a
+// warning recommending rebuild-fts when the index looks inconsistent
+func checkIndexIntegrity() {
+	pages, err := db.CheckFTS()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: index check failed: %v, run 'pdf-fts rebuild-fts' to repair it\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Index check: OK (%d pages)\n", pages)
+}
+
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose logging")
+	rootCmd.PersistentFlags().StringSliceVar(&dbBoundary, "db-boundary", config.DefaultBoundaryMarkers,
+		"files or directories marking the project root where the database search stops")
+	rootCmd.PersistentFlags().StringVar(&progressMode, "progress", progressBar, "progress display: bar, json (newline-delimited records) or none")
+	rootCmd.PersistentFlags().StringVar(&progressOutput, "progress-output", "stderr", "stream for json progress records: stdout or stderr")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", colorAuto, "colorize the output: always, auto (only on a terminal) or never")
+	rootCmd.PersistentFlags().IntVar(&dbOptions.MaxOpenConns, "db-max-open-conns", dbOptions.MaxOpenConns, "maximum open database connections, at least 2, or 0 for no limit")
+	rootCmd.PersistentFlags().IntVar(&dbOptions.MaxIdleConns, "db-max-idle-conns", dbOptions.MaxIdleConns, "maximum idle database connections kept open")
+	rootCmd.PersistentFlags().DurationVar(&dbOptions.BusyTimeout, "db-busy-timeout", dbOptions.BusyTimeout, "how long to wait for a database locked by another process")
+	rootCmd.PersistentFlags().IntVar(&dbOptions.CacheSize, "db-cache-size", dbOptions.CacheSize, "SQLite page cache size, in pages or in KiB when negative, 0 for the SQLite default")
+	rootCmd.PersistentFlags().Int64Var(&dbOptions.MmapSize, "db-mmap-size", dbOptions.MmapSize, "bytes of the database accessed through memory mapping, 0 for the SQLite default")
+	rootCmd.PersistentFlags().StringVar(&dbOptions.Synchronous, "db-synchronous", dbOptions.Synchronous, "SQLite synchronous mode: OFF, NORMAL, FULL or EXTRA, empty for the SQLite default")
+	rootCmd.PersistentFlags().BoolVar(&dbInfo, "db-info", false, "print the database the command would use and exit without running it")
+	rootCmd.PersistentFlags().BoolVar(&skipVersionCheck, "skip-version-check", false, "don't warn when the database was indexed by a different version")
+	rootCmd.PersistentFlags().BoolVar(&checkIntegrity, "check-integrity", false, "check that the index matches the stored pages and its triggers exist before running the command")
 }
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/lockfile"
 	"github.com/aziis98/pdf-fts/internal/pdf"
 	"github.com/aziis98/pdf-fts/internal/util"
-	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 )
 
@@ -22,31 +27,304 @@ var scanCmd = &cobra.Command{
 		and store it in the database for full-text search. Only processes
 		files that have changed since the last scan unless --force is used.
 		
-		If no folders are specified, scans the current directory.
+		If no folders are specified, scans the folders listed in the
+		PDF_FTS_SCAN_DIRS environment variable (separated like PATH), or the
+		current directory if it is unset.
 	`),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		force, _ := cmd.Flags().GetBool("force")
+		var opts scanOptions
+		opts.Force, _ = cmd.Flags().GetBool("force")
+		opts.Strict, _ = cmd.Flags().GetBool("strict")
+		opts.Cache, _ = cmd.Flags().GetBool("cache")
+		opts.Wait, _ = cmd.Flags().GetBool("wait")
+		opts.ExtractTimeout, _ = cmd.Flags().GetDuration("extract-timeout")
+		if maxExtracted, _ := cmd.Flags().GetString("max-extracted"); maxExtracted != "" {
+			var err error
+			if opts.MaxExtracted, err = parseFileSize(maxExtracted); err != nil {
+				return fmt.Errorf("invalid --max-extracted: %w", err)
+			}
+		}
+		opts.Checkpoint, _ = cmd.Flags().GetBool("checkpoint")
+		opts.StripBoilerplate, _ = cmd.Flags().GetBool("strip-boilerplate")
+		opts.BoilerplateThreshold, _ = cmd.Flags().GetFloat64("boilerplate-threshold")
+		opts.Bulk, _ = cmd.Flags().GetBool("bulk")
+		opts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+		opts.IndexAnnotations, _ = cmd.Flags().GetBool("index-annotations")
+		opts.StoreRaw, _ = cmd.Flags().GetBool("store-raw")
+		opts.History, _ = cmd.Flags().GetBool("history")
+		opts.TextFilters, _ = cmd.Flags().GetStringSlice("text-filters")
+		if _, err := pdf.LookupFilters(opts.TextFilters); err != nil {
+			return err
+		}
+		opts.WorkersIO, _ = cmd.Flags().GetInt("workers-io")
+		opts.WorkersCPU, _ = cmd.Flags().GetInt("workers-cpu")
+		opts.CollapseDuplicates, _ = cmd.Flags().GetBool("collapse-duplicate-pages")
+		indexEmpty, _ := cmd.Flags().GetBool("index-empty-pages")
+		opts.SkipEmptyPages = !indexEmpty
+		opts.MaxSegmentChars, _ = cmd.Flags().GetInt("max-segment-chars")
+		opts.Chunk, _ = cmd.Flags().GetString("chunk")
+		opts.ForceCheckpoint = opts.Checkpoint && cmd.Flags().Changed("checkpoint")
+		opts.DeleteMissing, _ = cmd.Flags().GetBool("delete-missing")
+		opts.GroupVolumes, _ = cmd.Flags().GetBool("group-volumes")
+		opts.Daemon, _ = cmd.Flags().GetBool("daemon")
+		opts.Interval, _ = cmd.Flags().GetDuration("interval")
+		if opts.Daemon && opts.Interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
 
 		folders := args
 		if len(folders) == 0 {
-			folders = []string{"."}
+			folders = defaultScanFolders()
 		}
 
-		return runScanCommand(folders, force)
+		return runScanCommand(folders, opts)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(scanCmd)
+	addDatabaseFlag(scanCmd)
 
 	scanCmd.Flags().BoolP("force", "f", false, "force re-scan of all PDFs")
+	scanCmd.Flags().Bool("wait", false, "wait for another running scan to finish instead of failing")
+	scanCmd.Flags().Int("workers-io", defaultWorkersIO, "number of files hashed in parallel (I/O-bound)")
+	scanCmd.Flags().Int("workers-cpu", defaultWorkersCPU, "number of files whose text is extracted in parallel (CPU-bound)")
+	scanCmd.Flags().Int("batch-size", 0, "write the pages of large documents in transactions of this many pages (0 for one per document)")
+	scanCmd.Flags().Bool("bulk", false, "disable the index triggers while storing pages and rebuild the index once at the end, faster for large initial scans")
+	scanCmd.Flags().Bool("cache", false, "cache extracted text by file hash and reuse it for moved or duplicated files")
+	scanCmd.Flags().Bool("strict", false, "exit with an error if any file failed to be hashed or extracted")
+	scanCmd.Flags().Bool("checkpoint", true, "checkpoint the WAL after the scan, automatic only when it grew past 16 MB unless set explicitly")
+	scanCmd.Flags().Bool("strip-boilerplate", false, "remove running headers and footers repeated across pages")
+	scanCmd.Flags().Float64("boilerplate-threshold", 0.5, "fraction of pages a line must appear on to be removed by --strip-boilerplate")
+	scanCmd.Flags().Bool("store-raw", false, "also store the text before cleaning, so 'reprocess' can apply new cleaning rules without the PDFs")
+	addTextFiltersFlag(scanCmd)
+	scanCmd.Flags().Bool("index-annotations", false, "also index the page annotations (currently link targets only)")
+	scanCmd.Flags().Bool("collapse-duplicate-pages", false, "index only the first of the pages of a document with identical content")
+	scanCmd.Flags().Bool("index-empty-pages", true, "store the pages without any text, set to false to keep them out of the index")
+	scanCmd.Flags().Int("max-segment-chars", 0, "split pages longer than this many characters into separately ranked segments (0 disables)")
+	addChunkFlag(scanCmd)
+	scanCmd.Flags().Bool("delete-missing", false, "also remove from the index the files under the scanned folders that no longer exist")
+	scanCmd.Flags().Bool("group-volumes", false, "group the files of a directory named as volumes of one document (like \"book vol1.pdf\"), see the volumes command")
+	scanCmd.Flags().Bool("history", true, "record the duration and counts of the scan, shown by the history command")
+	scanCmd.Flags().Bool("daemon", false, "keep running and rescan the folders every --interval until interrupted")
+	scanCmd.Flags().Duration("interval", 10*time.Minute, "time between the scans of --daemon")
+	scanCmd.Flags().Duration("extract-timeout", 0, "skip files whose text extraction takes longer than this (e.g. 30s, 0 disables)")
+	scanCmd.Flags().String("max-extracted", "", "skip files whose extracted text grows past this size (e.g. 50MB), stopping their extraction early")
+}
+
+// defaultScanOptions returns the options of a scan with the default flags,
+// for commands that index files as a follow-up
+func defaultScanOptions() scanOptions {
+	return scanOptions{
+		WorkersIO:            defaultWorkersIO,
+		WorkersCPU:           defaultWorkersCPU,
+		BoilerplateThreshold: 0.5,
+		TextFilters:          pdf.DefaultFilterNames,
+		Checkpoint:           true,
+		History:              true,
+	}
+}
+
+// addTextFiltersFlag adds the --text-filters flag selecting the cleaning
+// pipeline, shared by scan and reprocess
+func addTextFiltersFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("text-filters", pdf.DefaultFilterNames,
+		"comma separated text cleaning steps applied in order (filters: "+strings.Join(pdf.FilterNames(), ", ")+")")
+}
+
+// addChunkFlag adds the --chunk flag selecting the indexed unit, shared by
+// scan and reprocess
+func addChunkFlag(cmd *cobra.Command) {
+	cmd.Flags().String("chunk", "", "unit indexed as a row: page, or paragraph for tighter snippets and ranking (default: the mode of the database, else page)")
+}
+
+// chunkModeMeta is the meta key recording the chunk mode of the database
+const chunkModeMeta = "chunk_mode"
+
+// resolveChunkMode fills an empty opts.Chunk with the mode recorded in the
+// database and records a newly selected one. Documents indexed with another
+// mode keep their rows until they are scanned again.
+func resolveChunkMode(opts *scanOptions) error {
+	stored, err := db.GetMeta(chunkModeMeta)
+	if err != nil {
+		return err
+	}
+	if stored == "" {
+		stored = pdf.ChunkPage
+	}
+
+	switch opts.Chunk {
+	case "":
+		opts.Chunk = stored
+		return nil
+	case pdf.ChunkPage, pdf.ChunkParagraph:
+	default:
+		return fmt.Errorf("invalid --chunk %q, expected page or paragraph", opts.Chunk)
+	}
+
+	if opts.Chunk != stored {
+		fmt.Fprintf(os.Stderr, "Warning: switching the database from %s to %s chunks, documents already indexed keep theirs until indexed again (e.g. with scan --force)\n",
+			stored, opts.Chunk)
+	}
+	return db.SetMeta(chunkModeMeta, opts.Chunk)
+}
+
+// defaultExtraction reports whether pages are extracted with the default
+// options, cached extractions are only valid for those: the default text
+// filters, without annotations and without a --max-extracted limit, which
+// cached pages would bypass
+func defaultExtraction(opts scanOptions) bool {
+	return slices.Equal(opts.TextFilters, pdf.DefaultFilterNames) && !opts.IndexAnnotations && opts.MaxExtracted == 0
+}
+
+// scanDirsEnvVar lists the folders scanned when none are given, separated by
+// the OS path list separator like PATH
+const scanDirsEnvVar = "PDF_FTS_SCAN_DIRS"
+
+// defaultScanFolders returns the folders listed in scanDirsEnvVar, or the
+// current directory if there are none
+func defaultScanFolders() []string {
+	var folders []string
+	for _, folder := range filepath.SplitList(os.Getenv(scanDirsEnvVar)) {
+		if folder = strings.TrimSpace(folder); folder != "" {
+			folders = append(folders, folder)
+		}
+	}
+	if len(folders) == 0 {
+		return []string{"."}
+	}
+	if cfg.Verbose {
+		log.Printf("Scanning the folders from %s: %v", scanDirsEnvVar, folders)
+	}
+	return folders
+}
+
+// scanOptions holds the flags controlling a scan
+type scanOptions struct {
+	Force          bool
+	Strict         bool
+	Cache          bool
+	Wait           bool
+	ExtractTimeout time.Duration
+	// MaxExtracted is the size of raw text past which a file is skipped, 0
+	// disables it
+	MaxExtracted int64
+
+	// WorkersIO and WorkersCPU size the hashing and extraction worker pools
+	WorkersIO  int
+	WorkersCPU int
+
+	// BatchSize bounds the number of pages written per transaction
+	BatchSize int
+
+	// Bulk drops the FTS triggers during processing and rebuilds the index at the end
+	Bulk bool
+
+	StripBoilerplate     bool
+	BoilerplateThreshold float64
+
+	// IndexAnnotations adds the page annotations to the indexed content
+	IndexAnnotations bool
+
+	// StoreRaw keeps the uncleaned text of each page for reprocess
+	StoreRaw bool
+
+	// TextFilters names the cleaning steps applied to the extracted text, in order
+	TextFilters []string
+
+	// CollapseDuplicates keeps only the first page of each distinct content
+	CollapseDuplicates bool
+
+	// GroupVolumes groups the files named as volumes of one document after the scan
+	GroupVolumes bool
+
+	// DeleteMissing removes the indexed files under the scanned folders that
+	// no longer exist
+	DeleteMissing bool
+
+	// SkipEmptyPages doesn't store the pages without any text
+	SkipEmptyPages bool
+
+	// MaxSegmentChars splits longer pages into segments stored as separate rows
+	MaxSegmentChars int
+
+	// Chunk is the unit stored as a row, pdf.ChunkPage or pdf.ChunkParagraph,
+	// empty for the mode recorded in the database
+	Chunk string
+
+	// Checkpoint enables the WAL checkpoint at the end of the scan, it only
+	// runs past autoCheckpointSize unless ForceCheckpoint is set
+	Checkpoint      bool
+	ForceCheckpoint bool
+
+	// History records the scan run in the scan_history table
+	History bool
+
+	// Daemon repeats the scan every Interval until interrupted
+	Daemon   bool
+	Interval time.Duration
+}
+
+// Documents with at least minDuplicateWarnPages pages of which at least
+// duplicateWarnRatio repeat an earlier page (e.g. blank pages) get a warning
+const (
+	minDuplicateWarnPages = 20
+	duplicateWarnRatio    = 0.5
+)
+
+// autoCheckpointSize is the WAL size above which scans checkpoint automatically
+const autoCheckpointSize = 16 << 20
+
+func runScanCommand(folders []string, opts scanOptions) error {
+	if opts.Daemon {
+		return runScanDaemon(folders, opts)
+	}
+	_, err := scanFolders(folders, opts)
+	return err
 }
 
-func runScanCommand(folders []string, forceRescan bool) error {
+// scanStats counts what a scan did, summed across the cycles of a daemon
+type scanStats struct {
+	Found   int
+	Updated int
+	Failed  int
+	Removed int   // files deleted from disk removed with --delete-missing
+	Bytes   int64 // size of the files that needed processing
+
+	// UpdatedPaths are the files stored by the scan, not summed by a daemon
+	UpdatedPaths []string
+}
+
+// scanFolders runs an incremental scan of the given folders, recording it in
+// the scan history unless disabled
+func scanFolders(folders []string, opts scanOptions) (stats scanStats, err error) {
+	// Only one scan at a time can write to the database, readers are not affected
+	lock, err := acquireScanLock(opts.Wait)
+	if err != nil {
+		return stats, err
+	}
+	defer lock.Release()
+
+	if opts.History {
+		started := time.Now()
+		defer func() {
+			recordScanRun(started, stats)
+		}()
+	}
+
+	if err := resolveChunkMode(&opts); err != nil {
+		return stats, err
+	}
+
 	pdfProcessor := pdf.New(cfg.Verbose)
+	pdfProcessor.IndexAnnotations = opts.IndexAnnotations
+	pdfProcessor.MaxExtractedBytes = opts.MaxExtracted
+	if pdfProcessor.Filters, err = pdf.LookupFilters(opts.TextFilters); err != nil {
+		return stats, err
+	}
 
 	if cfg.Verbose {
-		log.Printf("Scanning folders: %v (force: %t)", folders, forceRescan)
+		log.Printf("Scanning folders: %v (force: %t)", folders, opts.Force)
 	}
 
 	// Phase 1: PDF Discovery/Crawl
@@ -58,7 +336,7 @@ func runScanCommand(folders []string, forceRescan bool) error {
 		}
 		pdfFiles, err := crawlPDFs(folder)
 		if err != nil {
-			return fmt.Errorf("crawling PDFs in %s: %w", folder, err)
+			return stats, fmt.Errorf("crawling PDFs in %s: %w", folder, err)
 		}
 		if cfg.Verbose {
 			log.Printf("Found %d PDF files in %s", len(pdfFiles), folder)
@@ -66,6 +344,15 @@ func runScanCommand(folders []string, forceRescan bool) error {
 		allPdfFiles = append(allPdfFiles, pdfFiles...)
 	}
 
+	if opts.DeleteMissing {
+		if stats.Removed, err = deleteMissingFiles(folders, allPdfFiles); err != nil {
+			return stats, err
+		}
+		if stats.Removed > 0 {
+			fmt.Printf("Removed %d missing file(s) from the index.\n", stats.Removed)
+		}
+	}
+
 	if len(allPdfFiles) == 0 {
 		fmt.Println("No PDF files found.")
 
@@ -76,20 +363,25 @@ func runScanCommand(folders []string, forceRescan bool) error {
 			log.Printf("Warning: Could not determine database size: %v", err)
 		}
 
-		return nil
+		return stats, nil
 	}
 
+	stats.Found = len(allPdfFiles)
 	fmt.Printf("Found %d PDF files.\n\n", len(allPdfFiles))
 
 	// Phase 2: Hash Checking
 	fmt.Println("Phase 2: Checking file hashes...")
-	filesToProcess, err := checkHashes(pdfProcessor, allPdfFiles, forceRescan)
+	filesToProcess, hashFailures, err := checkHashes(pdfProcessor, allPdfFiles, opts.Force, opts.WorkersIO)
 	if err != nil {
-		return fmt.Errorf("checking hashes: %w", err)
+		return stats, fmt.Errorf("checking hashes: %w", err)
 	}
+	stats.Failed = hashFailures
 
 	if len(filesToProcess) == 0 {
 		fmt.Println("All files are up to date. No processing needed.")
+		if opts.GroupVolumes {
+			groupVolumes()
+		}
 
 		// Show database file size
 		if dbSize, err := getDatabaseSize(); err == nil {
@@ -98,19 +390,62 @@ func runScanCommand(folders []string, forceRescan bool) error {
 			log.Printf("Warning: Could not determine database size: %v", err)
 		}
 
-		return nil
+		return stats, reportFailures(hashFailures, opts.Strict)
 	}
 
 	fmt.Printf("%d files need processing.\n\n", len(filesToProcess))
+	for _, fileInfo := range filesToProcess {
+		stats.Bytes += fileInfo.Size
+	}
+
+	// A broken MuPDF would otherwise fail every file with the same error
+	if err := pdf.CheckMuPDF(); err != nil {
+		return stats, err
+	}
 
 	// Phase 3: PDF Processing
 	fmt.Println("Phase 3: Processing PDF content...")
-	processedCount, err := processPDFs(pdfProcessor, filesToProcess)
+	processed, processFailures, err := processPDFsBulk(pdfProcessor, filesToProcess, opts)
 	if err != nil {
-		return fmt.Errorf("processing PDFs: %w", err)
+		return stats, fmt.Errorf("processing PDFs: %w", err)
+	}
+	processedCount := len(processed)
+	stats.Updated = processedCount
+	stats.UpdatedPaths = processed
+	stats.Failed += processFailures
+	if processedCount > 0 {
+		if err := db.BumpGeneration(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to invalidate cached searches: %v\n", err)
+		}
+	}
+
+	if opts.DeleteMissing {
+		fmt.Printf("\nScan completed. Processed %d PDFs, updated %d entries, removed %d missing.\n", len(allPdfFiles), processedCount, stats.Removed)
+	} else {
+		fmt.Printf("\nScan completed. Processed %d PDFs, updated %d entries.\n", len(allPdfFiles), processedCount)
+	}
+
+	if opts.GroupVolumes {
+		groupVolumes()
+	}
+
+	// Only a forced scan of every indexed file brings the data to the current
+	// schema. Files that failed keep their previous pages and are reported
+	// on their own, they don't hold back the others.
+	fullRescan := opts.Force
+	if fullRescan {
+		var coverErr error
+		if fullRescan, coverErr = coversIndex(allPdfFiles); coverErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to check the rescanned files: %v\n", coverErr)
+		}
+	}
+	if err := db.RecordScan(version, fullRescan); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to record scan version: %v\n", err)
 	}
 
-	fmt.Printf("\nScan completed. Processed %d PDFs, updated %d entries.\n", len(allPdfFiles), processedCount)
+	if opts.Checkpoint {
+		checkpointWAL(opts.ForceCheckpoint)
+	}
 
 	// Show database file size
 	if dbSize, err := getDatabaseSize(); err == nil {
@@ -119,12 +454,75 @@ func runScanCommand(folders []string, forceRescan bool) error {
 		log.Printf("Warning: Could not determine database size: %v", err)
 	}
 
+	return stats, reportFailures(stats.Failed, opts.Strict)
+}
+
+// groupVolumes regroups the volumes of the indexed files, only warning on failure
+func groupVolumes() {
+	if documents, err := db.GroupVolumes(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to group volumes: %v\n", err)
+	} else if cfg.Verbose {
+		log.Printf("Grouped volumes into %d document(s)", documents)
+	}
+}
+
+// recordScanRun stores a scan run in the history, only warning on failure
+func recordScanRun(started time.Time, stats scanStats) {
+	run := database.ScanRun{
+		StartedAt: started,
+		Duration:  time.Since(started),
+		Found:     stats.Found,
+		Processed: stats.Updated,
+		Failed:    stats.Failed,
+		Bytes:     stats.Bytes,
+	}
+	if err := db.RecordScanRun(run); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to record the scan in the history: %v\n", err)
+	}
+}
+
+// acquireScanLock takes the lock file next to the database, failing if another
+// scan holds it unless wait is set. In-memory databases belong to this process
+// and need no lock, a nil one is returned.
+func acquireScanLock(wait bool) (*lockfile.Lock, error) {
+	if database.IsMemory(cfg.DBPath) {
+		return nil, nil
+	}
+	lockPath := cfg.DBPath + ".lock"
+
+	lock, err := lockfile.TryAcquire(lockPath)
+	if err == nil {
+		return lock, nil
+	}
+	if !errors.Is(err, lockfile.ErrLocked) {
+		return nil, err
+	}
+	if !wait {
+		return nil, fmt.Errorf("another scan is running on this database (lock file %s), use --wait to wait for it", lockPath)
+	}
+
+	fmt.Println("Waiting for another scan to finish...")
+	return lockfile.Acquire(lockPath)
+}
+
+// reportFailures prints how many files failed and, in strict mode, turns any
+// failure into an error so the command exits non-zero
+func reportFailures(failed int, strict bool) error {
+	if failed == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "%d file(s) could not be scanned.\n", failed)
+	if strict {
+		return fmt.Errorf("%d file(s) failed to scan", failed)
+	}
 	return nil
 }
 
 // PDFFileInfo holds information about a PDF file to be processed
 type PDFFileInfo struct {
 	Path        string
+	Size        int64
 	CurrentHash string
 	StoredHash  string
 	NeedsUpdate bool
@@ -156,154 +554,441 @@ func crawlPDFs(folder string) ([]string, error) {
 	return pdfFiles, err
 }
 
-// checkHashes checks which files need to be processed based on hash comparison
-func checkHashes(pdfProcessor *pdf.Extractor, pdfFiles []string, forceRescan bool) ([]PDFFileInfo, error) {
-	var filesToProcess []PDFFileInfo
+// coversIndex reports whether every indexed file still on disk is among the
+// scanned ones. Deleted files are left out, they can't be extracted again.
+func coversIndex(scanned []string) (bool, error) {
+	crawled := make(map[string]bool, len(scanned))
+	for _, path := range scanned {
+		crawled[path] = true
+	}
 
-	// Create progress bar for hash checking (only if not in verbose mode)
-	var bar *progressbar.ProgressBar
-	if !cfg.Verbose {
-		bar = progressbar.NewOptions(len(pdfFiles),
-			progressbar.OptionSetDescription("Checking hashes"),
-			progressbar.OptionSetWidth(50),
-			progressbar.OptionShowCount(),
-			progressbar.OptionShowIts(),
-			progressbar.OptionSetTheme(progressbar.Theme{
-				Saucer:        "=",
-				SaucerHead:    ">",
-				SaucerPadding: " ",
-				BarStart:      "[",
-				BarEnd:        "]",
-			}))
-	}
-
-	for i, path := range pdfFiles {
-		if cfg.Verbose {
-			log.Printf("[%d/%d] Checking hash for: %s", i+1, len(pdfFiles), path)
+	paths, err := db.IndexedPaths("")
+	if err != nil {
+		return false, err
+	}
+	for _, path := range paths {
+		if crawled[path] {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return false, nil
 		}
+	}
+	return true, nil
+}
 
-		// Calculate current file hash
-		currentHash, err := pdfProcessor.HashFile(path)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to calculate hash for %s: %v\n", path, err)
-			if bar != nil {
-				bar.Add(1)
-			}
+// deleteMissingFiles removes from the index the files under the scanned
+// folders that the crawl didn't find and that no longer exist on disk,
+// returning how many were removed. Folders that don't exist, like an
+// unmounted drive, are skipped rather than emptied.
+func deleteMissingFiles(folders, found []string) (int, error) {
+	crawled := make(map[string]bool, len(found))
+	for _, path := range found {
+		crawled[path] = true
+	}
+
+	paths, err := db.IndexedPaths("")
+	if err != nil {
+		return 0, err
+	}
+
+	var missing []string
+	for _, folder := range folders {
+		if _, err := os.Stat(folder); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Not removing missing files under %s: %v\n", folder, err)
 			continue
 		}
 
-		// Get stored hash from database
-		storedHash, err := db.GetStoredHash(path)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to get stored hash for %s: %v\n", path, err)
-			if bar != nil {
-				bar.Add(1)
+		for _, path := range paths {
+			if crawled[path] || !underFolder(path, folder) {
+				continue
 			}
-			continue
+			if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if cfg.Verbose {
+				log.Printf("Removing missing file: %s", path)
+			}
+			missing = append(missing, path)
+			crawled[path] = true // Scanned folders may overlap
 		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
 
-		needsUpdate := forceRescan || currentHash != storedHash
+	if err := db.RemoveFiles(missing); err != nil {
+		return 0, err
+	}
+	if err := db.BumpGeneration(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to invalidate cached searches: %v\n", err)
+	}
+	return len(missing), nil
+}
 
-		if needsUpdate {
-			if cfg.Verbose {
-				if storedHash == "" {
-					log.Printf("File is new, will be processed: %s", path)
-				} else if forceRescan {
-					log.Printf("Force rescan enabled, will process: %s", path)
-				} else {
-					log.Printf("File hash changed (stored: %s, current: %s), will be processed: %s",
-						storedHash[:min(8, len(storedHash))],
-						currentHash[:min(8, len(currentHash))],
-						path)
-				}
-			}
+// underFolder reports whether a stored path is inside a scanned folder, both
+// as given on the command line, relative or absolute
+func underFolder(path, folder string) bool {
+	rel, err := filepath.Rel(folder, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
 
-			filesToProcess = append(filesToProcess, PDFFileInfo{
-				Path:        path,
-				CurrentHash: currentHash,
-				StoredHash:  storedHash,
-				NeedsUpdate: true,
-			})
-		} else {
-			if cfg.Verbose {
-				log.Printf("File up to date (hash: %s): %s", currentHash[:min(8, len(currentHash))], path)
-			}
+// checkHashes checks which files need to be processed based on hash comparison,
+// also returning the number of files that couldn't be checked
+func checkHashes(pdfProcessor *pdf.Extractor, pdfFiles []string, forceRescan bool, workers int) ([]PDFFileInfo, int, error) {
+	var filesToProcess []PDFFileInfo
+	failed := 0
+
+	progress := newProgress("hashing", "Checking hashes", len(pdfFiles))
+
+	// Hashes are computed in parallel, results are kept in the crawl order
+	infos := make([]*PDFFileInfo, len(pdfFiles))
+	parallelEach(workers, len(pdfFiles), func(i int) {
+		infos[i] = checkHash(pdfProcessor, pdfFiles[i], forceRescan)
+	}, func(i int) {
+		progress.Step(pdfFiles[i])
+	})
+	progress.Finish()
+
+	for _, info := range infos {
+		switch {
+		case info == nil:
+			failed++
+		case info.NeedsUpdate:
+			filesToProcess = append(filesToProcess, *info)
 		}
+	}
+
+	return filesToProcess, failed, nil
+}
 
-		if bar != nil {
-			bar.Add(1)
+// checkHash compares the current and stored hash of a file, returning nil if
+// either can't be read
+func checkHash(pdfProcessor *pdf.Extractor, path string, forceRescan bool) *PDFFileInfo {
+	if cfg.Verbose {
+		log.Printf("Checking hash for: %s", path)
+	}
+
+	// Calculate current file hash
+	currentHash, err := pdfProcessor.HashFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to calculate hash for %s: %v\n", path, err)
+		return nil
+	}
+
+	// Get stored hash from database
+	storedHash, err := db.GetStoredHash(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to get stored hash for %s: %v\n", path, err)
+		return nil
+	}
+
+	needsUpdate := forceRescan || currentHash != storedHash
+
+	// The size is only used for statistics
+	var size int64
+	if stat, err := os.Stat(path); err == nil {
+		size = stat.Size()
+	}
+
+	if cfg.Verbose {
+		switch {
+		case !needsUpdate:
+			log.Printf("File up to date (hash: %s): %s", currentHash[:min(8, len(currentHash))], path)
+		case storedHash == "":
+			log.Printf("File is new, will be processed: %s", path)
+		case forceRescan:
+			log.Printf("Force rescan enabled, will process: %s", path)
+		default:
+			log.Printf("File hash changed (stored: %s, current: %s), will be processed: %s",
+				storedHash[:min(8, len(storedHash))],
+				currentHash[:min(8, len(currentHash))],
+				path)
 		}
 	}
 
-	if bar != nil {
-		fmt.Println() // New line after progress bar
+	return &PDFFileInfo{
+		Path:        path,
+		Size:        size,
+		CurrentHash: currentHash,
+		StoredHash:  storedHash,
+		NeedsUpdate: needsUpdate,
 	}
-	return filesToProcess, nil
 }
 
-// processPDFs processes the PDF content for files that need updating
-func processPDFs(pdfProcessor *pdf.Extractor, filesToProcess []PDFFileInfo) (int, error) {
-	processedCount := 0
+// processPDFs processes the PDF content for files that need updating, returning
+// the files stored and the number of files that failed
+func processPDFs(pdfProcessor *pdf.Extractor, filesToProcess []PDFFileInfo, opts scanOptions) ([]string, int, error) {
+	var processed []string
+	failed := 0
 
-	// Create progress bar for PDF processing (only if not in verbose mode)
-	var bar *progressbar.ProgressBar
-	if !cfg.Verbose {
-		bar = progressbar.NewOptions(len(filesToProcess),
-			progressbar.OptionSetDescription("Processing PDFs"),
-			progressbar.OptionSetWidth(50),
-			progressbar.OptionShowCount(),
-			progressbar.OptionShowIts(),
-			progressbar.OptionSetTheme(progressbar.Theme{
-				Saucer:        "=",
-				SaucerHead:    ">",
-				SaucerPadding: " ",
-				BarStart:      "[",
-				BarEnd:        "]",
-			}))
-	}
+	progress := newProgress("processing", "Processing PDFs", len(filesToProcess))
+
+	// Text is extracted in parallel, pages are stored from this goroutine as
+	// each file completes since SQLite has a single writer
+	extracted := make([][]pdf.Page, len(filesToProcess))
+	extractedRaw := make([][]database.RawPage, len(filesToProcess))
+	extractedSections := make([][]database.Section, len(filesToProcess))
+	extractErrs := make([]error, len(filesToProcess))
+	var tooLarge []string
+
+	parallelEach(opts.WorkersCPU, len(filesToProcess), func(i int) {
+		extracted[i], extractedRaw[i], extractErrs[i] = preparePages(pdfProcessor, filesToProcess[i], opts)
+		if extractErrs[i] == nil {
+			extractedSections[i] = extractSections(pdfProcessor, filesToProcess[i].Path)
+		}
+	}, func(i int) {
+		fileInfo := filesToProcess[i]
+		pages, raw, sections := extracted[i], extractedRaw[i], extractedSections[i]
+		extracted[i], extractedRaw[i], extractedSections[i] = nil, nil, nil // Release the text once stored
 
-	for i, fileInfo := range filesToProcess {
 		if cfg.Verbose {
-			log.Printf("[%d/%d] Processing PDF content: %s", i+1, len(filesToProcess), fileInfo.Path)
+			log.Printf("[%d/%d] Processed PDF content: %s", len(processed)+failed+1, len(filesToProcess), fileInfo.Path)
 		}
 
-		// Extract text content per page
-		pageContents, err := pdfProcessor.ExtractPagesText(fileInfo.Path)
-		if err != nil {
+		if err := extractErrs[i]; err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: Failed to process %s: %v\n", fileInfo.Path, err)
-			if bar != nil {
-				bar.Add(1)
+			if errors.Is(err, pdf.ErrTooMuchText) {
+				tooLarge = append(tooLarge, fileInfo.Path)
 			}
-			continue
-		}
-
-		if cfg.Verbose {
-			log.Printf("Extracted text from %d pages in: %s", len(pageContents), fileInfo.Path)
+			failed++
+			progress.Step(fileInfo.Path)
+			return
 		}
 
 		// Update database
-		if err := db.UpsertPDFData(fileInfo.Path, fileInfo.CurrentHash, pageContents); err != nil {
+		if err := db.UpsertPDFData(fileInfo.Path, fileInfo.CurrentHash, toDBPages(pages), opts.BatchSize); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: Failed to store data for %s: %v\n", fileInfo.Path, err)
-			if bar != nil {
-				bar.Add(1)
+			failed++
+			progress.Step(fileInfo.Path)
+			return
+		}
+
+		// Without --store-raw this removes the raw text of a previous version,
+		// the raw text of an unchanged file rescanned without it is kept
+		if raw != nil || fileInfo.CurrentHash != fileInfo.StoredHash {
+			if err := db.StoreRawPages(fileInfo.Path, raw); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to store the raw text of %s: %v\n", fileInfo.Path, err)
 			}
-			continue
+		}
+		if err := db.StoreSections(fileInfo.Path, sections); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to store the outline of %s: %v\n", fileInfo.Path, err)
 		}
 
-		processedCount++
+		processed = append(processed, fileInfo.Path)
 		if cfg.Verbose {
 			log.Printf("Successfully updated database entry for: %s", fileInfo.Path)
 		}
 
-		if bar != nil {
-			bar.Add(1)
+		progress.Step(fileInfo.Path)
+	})
+
+	progress.Finish()
+
+	if len(tooLarge) > 0 {
+		fmt.Fprintf(os.Stderr, "%d file(s) skipped for extracting more than %s of text:\n", len(tooLarge), formatFileSize(opts.MaxExtracted))
+		for _, path := range tooLarge {
+			fmt.Fprintf(os.Stderr, "  %s\n", path)
+		}
+	}
+	return processed, failed, nil
+}
+
+// preparePages extracts the text of a file and applies the page filters
+// selected for the scan. The raw text of the pages is returned with --store-raw.
+func preparePages(pdfProcessor *pdf.Extractor, fileInfo PDFFileInfo, opts scanOptions) ([]pdf.Page, []database.RawPage, error) {
+	pages, err := extractPages(pdfProcessor, fileInfo, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Verbose {
+		log.Printf("Extracted text from %d pages in: %s", len(pages), fileInfo.Path)
+	}
+
+	var raw []database.RawPage
+	if opts.StoreRaw {
+		raw = rawPages(pages)
+	}
+
+	return filterPages(pdfProcessor, fileInfo.Path, pages, opts), raw, nil
+}
+
+// extractSections returns the outline of a document for search --section. A
+// document whose outline can't be read is indexed without one.
+func extractSections(pdfProcessor *pdf.Extractor, path string) []database.Section {
+	outline, err := pdfProcessor.ExtractOutline(path)
+	if err != nil {
+		if cfg.Verbose {
+			log.Printf("Warning: Could not read the outline of %s: %v", path, err)
 		}
+		return nil
 	}
 
-	if bar != nil {
-		fmt.Println() // New line after progress bar
+	sections := make([]database.Section, len(outline))
+	for i, section := range outline {
+		sections[i] = database.Section{
+			Level:    section.Level,
+			Title:    section.Title,
+			FromPage: section.FromPage,
+			ToPage:   section.ToPage,
+		}
 	}
-	return processedCount, nil
+	return sections
+}
+
+// filterPages applies the page filters selected for the scan to the pages
+// extracted from a file
+func filterPages(pdfProcessor *pdf.Extractor, path string, pages []pdf.Page, opts scanOptions) []pdf.Page {
+	if opts.StripBoilerplate {
+		pages = pdfProcessor.StripBoilerplate(pages, opts.BoilerplateThreshold)
+	}
+
+	if len(pages) >= minDuplicateWarnPages {
+		if ratio := pdf.DuplicatePageRatio(pages); ratio >= duplicateWarnRatio {
+			if opts.CollapseDuplicates {
+				if cfg.Verbose {
+					log.Printf("Collapsing duplicate pages (%.0f%% of %d) in: %s", ratio*100, len(pages), path)
+				}
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: %.0f%% of the %d pages of %s are duplicates, consider --collapse-duplicate-pages\n",
+					ratio*100, len(pages), path)
+			}
+		}
+	}
+	if opts.CollapseDuplicates {
+		pages = pdf.CollapseDuplicatePages(pages)
+	}
+	if opts.SkipEmptyPages {
+		pages = pdf.DropEmptyPages(pages)
+	}
+
+	if opts.Chunk == pdf.ChunkParagraph {
+		pages = pdf.SplitParagraphs(pages)
+	}
+
+	return pdf.SplitLongPages(pages, opts.MaxSegmentChars)
+}
+
+// processPDFsBulk runs processPDFs, and with --bulk drops the FTS triggers
+// before and rebuilds the index after, even if processing fails midway
+func processPDFsBulk(pdfProcessor *pdf.Extractor, files []PDFFileInfo, opts scanOptions) ([]string, int, error) {
+	if !opts.Bulk {
+		return processPDFs(pdfProcessor, files, opts)
+	}
+
+	if err := db.DropTriggers(); err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		fmt.Println("Rebuilding Full-Text Search index...")
+		if _, err := db.RebuildFTS(nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to rebuild the index after bulk import, run 'pdf-fts rebuild-fts': %v\n", err)
+		}
+	}()
+
+	return processPDFs(pdfProcessor, files, opts)
+}
+
+// extractPages extracts the pages of a file, reusing a previous extraction of
+// the same content from the cache when enabled and not forcing a re-scan
+func extractPages(pdfProcessor *pdf.Extractor, fileInfo PDFFileInfo, opts scanOptions) ([]pdf.Page, error) {
+	// The cache only holds pages extracted with the default options
+	cache := opts.Cache && defaultExtraction(opts)
+
+	// Cached pages have no raw text, extract again when it has to be stored
+	if cache && !opts.Force && !opts.StoreRaw {
+		cached, err := db.GetCachedPages(fileInfo.CurrentHash)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil {
+			if cfg.Verbose {
+				log.Printf("Reusing cached extraction (hash: %s) for: %s", fileInfo.CurrentHash[:min(8, len(fileInfo.CurrentHash))], fileInfo.Path)
+			}
+			return fromDBPages(cached), nil
+		}
+	}
+
+	pages, err := pdfProcessor.ExtractPagesTimeout(fileInfo.Path, opts.ExtractTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache {
+		if err := db.CachePages(fileInfo.CurrentHash, toDBPages(pages)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to cache extraction of %s: %v\n", fileInfo.Path, err)
+		}
+	}
+
+	return pages, nil
+}
+
+// fromDBPages converts stored pages back into extracted pages
+func fromDBPages(dbPages []database.Page) []pdf.Page {
+	pages := make([]pdf.Page, len(dbPages))
+	for i, page := range dbPages {
+		pages[i] = pdf.Page{
+			Content:  page.Content,
+			Original: page.Original,
+			Quality:  pdf.TextQuality(page.Content),
+		}
+	}
+	return pages
+}
+
+// toDBPages converts extracted pages into their database representation
+func toDBPages(pages []pdf.Page) []database.Page {
+	dbPages := make([]database.Page, len(pages))
+	for i, page := range pages {
+		dbPages[i] = database.Page{
+			Content:  page.Content,
+			Original: page.Original,
+			PageNum:  page.PageNum,
+			Quality:  page.Quality,
+		}
+	}
+	return dbPages
+}
+
+// rawPages returns the raw text of extracted pages, to store with --store-raw
+func rawPages(pages []pdf.Page) []database.RawPage {
+	raw := make([]database.RawPage, len(pages))
+	for i, page := range pages {
+		raw[i] = database.RawPage{Text: page.Raw, Annotations: page.Annotations}
+	}
+	return raw
+}
+
+// checkpointWAL truncates the WAL file if it grew past autoCheckpointSize, or
+// unconditionally if force is set, and reports the reclaimed space
+func checkpointWAL(force bool) {
+	if database.IsMemory(cfg.DBPath) {
+		return
+	}
+	walPath := cfg.DBPath + "-wal"
+
+	walInfo, err := os.Stat(walPath)
+	if err != nil {
+		if cfg.Verbose {
+			log.Printf("Warning: Could not determine WAL size: %v", err)
+		}
+		return
+	}
+	if !force && walInfo.Size() < autoCheckpointSize {
+		return
+	}
+
+	if err := db.Checkpoint(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to checkpoint database: %v\n", err)
+		return
+	}
+
+	var walSizeAfter int64
+	if info, err := os.Stat(walPath); err == nil {
+		walSizeAfter = info.Size()
+	}
+	fmt.Printf("Checkpointed database, reclaimed %s.\n", formatFileSize(walInfo.Size()-walSizeAfter))
 }
 
 // getDatabaseSize returns the size of the database file in bytes
@@ -312,6 +997,9 @@ func getDatabaseSize() (int64, error) {
 	if dbPath == "" {
 		return 0, fmt.Errorf("database path not configured")
 	}
+	if database.IsMemory(dbPath) {
+		return 0, fmt.Errorf("the database is in memory")
+	}
 
 	fileInfo, err := os.Stat(dbPath)
 	if err != nil {
@@ -321,6 +1009,32 @@ func getDatabaseSize() (int64, error) {
 	return fileInfo.Size(), nil
 }
 
+// parseFileSize parses a size in bytes with an optional unit, like 512KB or
+// 50MB, in the powers of 1024 used by formatFileSize
+func parseFileSize(value string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+	}
+
+	text := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(text, unit.suffix); ok {
+			text, multiplier = strings.TrimSpace(number), unit.size
+			break
+		}
+	}
+
+	size, err := strconv.ParseFloat(text, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("%q is not a size like 500KB or 50MB", value)
+	}
+	return int64(size * float64(multiplier)), nil
+}
+
 // formatFileSize formats a file size in bytes into a human-readable string
 func formatFileSize(bytes int64) string {
 	const unit = 1024
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestParseFileSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "50MB", want: 50 << 20},
+		{value: "512kb", want: 512 << 10},
+		{value: "1.5GB", want: 3 << 29},
+		{value: "2TB", want: 2 << 40},
+		{value: "100", want: 100},
+		{value: "100B", want: 100},
+		{value: " 2 KB ", want: 2 << 10},
+		{value: "abc", wantErr: true},
+		{value: "-5MB", wantErr: true},
+		{value: "MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseFileSize(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %d (%v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
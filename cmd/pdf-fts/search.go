@@ -1,53 +1,705 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/pdf"
 	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/aziis98/pdf-fts/internal/viewer"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
 	"github.com/spf13/cobra"
 )
 
 var (
-	spaceNormalizer       = regexp.MustCompile(`\s+`)
 	sqliteTimestampFormat = "2006-01-02 15:04:05"
 )
 
+// errNoResults is returned when a command needing a result finds none
+var errNoResults = errors.New("no results found")
+
 var searchCmd = &cobra.Command{
-	Use:   "search <query>",
+	Use:   "search <query> | search --like <path> | search --query-file <file>",
 	Short: "Search for text in PDFs",
 	Long: util.Dedent(`
 		Search for text content within indexed PDF files using full-text search.
 		Returns matching documents with highlighted snippets showing the search context.
+
+		The query is passed to SQLite FTS5 as written, so operators like OR, NOT,
+		NEAR() and quoted phrases work. With --and or --or every word is matched
+		literally instead, operators included.
+
+		With --like the query is built from the most distinctive words of an indexed
+		document, to find the documents sharing the most words with it.
+
+		With --query-file the queries are read from a file, one per line (blank
+		lines and lines starting with # are skipped), and each is searched in turn
+		with the same flags, the results grouped by query.
 	`),
-	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		query := strings.Join(args, " ")
-		limit, _ := cmd.Flags().GetInt("limit")
 
-		return runSearchCommand(query, limit)
+		var opts searchOptions
+		opts.Databases, _ = cmd.Flags().GetStringArray("db")
+		if len(opts.Databases) > 1 {
+			if err := openSearchDatabases(opts.Databases); err != nil {
+				return err
+			}
+			defer closeSearchDatabases()
+		}
+		opts.Like, _ = cmd.Flags().GetString("like")
+		opts.QueryFile, _ = cmd.Flags().GetString("query-file")
+		if opts.Like == "" && opts.QueryFile == "" && len(args) == 0 {
+			return fmt.Errorf("requires a query, a document with --like or a --query-file")
+		}
+		if opts.Like != "" && len(args) > 0 {
+			return fmt.Errorf("--like can't be combined with a query")
+		}
+		if opts.QueryFile != "" && len(args) > 0 {
+			return fmt.Errorf("--query-file can't be combined with a query")
+		}
+		if opts.Like != "" && cmd.Flags().Changed("scope") {
+			return fmt.Errorf("--like can't be combined with --scope")
+		}
+		opts.Limit, _ = cmd.Flags().GetInt("limit")
+		opts.Offset, _ = cmd.Flags().GetInt("offset")
+		opts.MaxResults, _ = cmd.Flags().GetInt("max-results")
+		opts.Plain, _ = cmd.Flags().GetBool("plain")
+		opts.Oneline, _ = cmd.Flags().GetBool("oneline")
+		opts.JSONLines, _ = cmd.Flags().GetBool("json-lines")
+		opts.Format, _ = cmd.Flags().GetString("format")
+		if opts.Format != formatText && opts.Format != formatCSV {
+			return fmt.Errorf("invalid --format %q, expected text or csv", opts.Format)
+		}
+		opts.NormalizeQuery, _ = cmd.Flags().GetString("normalize-query")
+		if opts.NormalizeQuery != normalizeOn && opts.NormalizeQuery != normalizeOff {
+			return fmt.Errorf("invalid --normalize-query %q, expected on or off", opts.NormalizeQuery)
+		}
+		opts.Fuzzy, _ = cmd.Flags().GetBool("fuzzy")
+		opts.Exact, _ = cmd.Flags().GetBool("exact")
+		opts.OutputFields, _ = cmd.Flags().GetStringSlice("fields")
+		if len(opts.OutputFields) > 0 && !opts.JSONLines {
+			return fmt.Errorf("--fields requires --json-lines")
+		}
+		if cmd.Flags().Changed("with-pages") {
+			if !opts.JSONLines {
+				return fmt.Errorf("--with-pages requires --json-lines")
+			}
+			opts.WithPages, _ = cmd.Flags().GetInt("with-pages")
+			if opts.WithPages < 0 || opts.WithPages > maxWithPages {
+				return fmt.Errorf("--with-pages must be between 0 and %d", maxWithPages)
+			}
+			opts.IncludePages = true
+		}
+		if err := validateOutputFields(opts.OutputFields, jsonFields); err != nil {
+			return err
+		}
+		opts.Fields, _ = cmd.Flags().GetStringArray("field")
+		opts.Explain, _ = cmd.Flags().GetBool("explain")
+		opts.OpenFirst, _ = cmd.Flags().GetBool("open-first")
+		opts.ExportMatches, _ = cmd.Flags().GetString("export-matches")
+		opts.ExportDPI, _ = cmd.Flags().GetFloat64("export-dpi")
+		if opts.ExportDPI <= 0 {
+			return fmt.Errorf("--export-dpi must be positive")
+		}
+		opts.Relative, _ = cmd.Flags().GetBool("relative")
+		opts.SnippetEllipsis, _ = cmd.Flags().GetString("snippet-ellipsis")
+		opts.TrimSnippetWhitespace, _ = cmd.Flags().GetBool("trim-snippet-whitespace")
+		opts.HighlightQueryOnly, _ = cmd.Flags().GetBool("highlight-query-only")
+		opts.SnippetWidth, _ = cmd.Flags().GetInt("max-snippet-width")
+		switch {
+		case opts.SnippetWidth < 0:
+			return fmt.Errorf("--max-snippet-width must not be negative")
+		case opts.SnippetWidth == 0:
+			opts.SnippetWidth = terminalSnippetWidth()
+		case opts.SnippetWidth < minSnippetWidth:
+			opts.SnippetWidth = minSnippetWidth
+		}
+		// Without weight flags the database's rank weights apply
+		if cmd.Flags().Changed("filename-weight") || cmd.Flags().Changed("content-weight") {
+			opts.FilenameWeight, _ = cmd.Flags().GetFloat64("filename-weight")
+			opts.ContentWeight, _ = cmd.Flags().GetFloat64("content-weight")
+		}
+		opts.DistinctFiles, _ = cmd.Flags().GetBool("distinct-files")
+		opts.DedupeBy, _ = cmd.Flags().GetString("dedupe-by")
+		if opts.DedupeBy != dedupeNone && opts.DedupeBy != dedupeContent {
+			return fmt.Errorf("invalid --dedupe-by %q, expected none or content", opts.DedupeBy)
+		}
+		opts.Sort, _ = cmd.Flags().GetString("sort")
+		if opts.Sort != sortRelevance && opts.Sort != sortLength {
+			return fmt.Errorf("invalid --sort %q, expected relevance or length", opts.Sort)
+		}
+		opts.MinPageChars, _ = cmd.Flags().GetInt("min-page-chars")
+		if excludeEmpty, _ := cmd.Flags().GetBool("exclude-empty"); excludeEmpty && opts.MinPageChars == 0 {
+			opts.MinPageChars = emptyPageChars
+		}
+		opts.MinQuality, _ = cmd.Flags().GetFloat64("min-quality")
+		opts.MaxRank, _ = cmd.Flags().GetFloat64("max-rank")
+		if opts.MaxRank > 0 {
+			return fmt.Errorf("--max-rank must be negative, bm25 scores are negative and lower is more relevant")
+		}
+		opts.OnlyRead, _ = cmd.Flags().GetBool("read")
+		opts.OnlyUnread, _ = cmd.Flags().GetBool("unread")
+		opts.Section, _ = cmd.Flags().GetString("section")
+		if since, _ := cmd.Flags().GetString("since"); since != "" {
+			var err error
+			if opts.Since, err = parseSince(since); err != nil {
+				return err
+			}
+		}
+		if pages, _ := cmd.Flags().GetString("pages"); pages != "" {
+			var err error
+			opts.FromPage, opts.ToPage, err = parsePageRange(pages)
+			if err != nil {
+				return err
+			}
+		}
+		opts.InDir, _ = cmd.Flags().GetString("in")
+		opts.PathGlob, _ = cmd.Flags().GetString("path")
+		opts.PathCI, _ = cmd.Flags().GetBool("path-ci")
+		opts.LineContext, _ = cmd.Flags().GetBool("line-context")
+		opts.SnippetBefore, _ = cmd.Flags().GetInt("snippet-before")
+		opts.SnippetAfter, _ = cmd.Flags().GetInt("snippet-after")
+		opts.AfterContext, _ = cmd.Flags().GetInt("after-context")
+		opts.BeforeContext, _ = cmd.Flags().GetInt("before-context")
+		// --show-context-pages sets both directions unless given explicitly
+		if contextPages, _ := cmd.Flags().GetInt("show-context-pages"); contextPages > 0 {
+			if !cmd.Flags().Changed("after-context") {
+				opts.AfterContext = contextPages
+			}
+			if !cmd.Flags().Changed("before-context") {
+				opts.BeforeContext = contextPages
+			}
+		}
+		opts.GroupContext, _ = cmd.Flags().GetBool("group-context")
+		opts.Scope, _ = cmd.Flags().GetString("scope")
+		switch opts.Scope {
+		case scopePage:
+		case scopeDocument:
+			if cmd.Flags().Changed("or") || cmd.Flags().Changed("fuzzy") {
+				return fmt.Errorf("--scope document can't be combined with --or or --fuzzy")
+			}
+		default:
+			return fmt.Errorf("invalid --scope %q, expected page or document", opts.Scope)
+		}
+		if useOr, _ := cmd.Flags().GetBool("or"); useOr {
+			opts.Operator = "OR"
+		} else if useAnd, _ := cmd.Flags().GetBool("and"); useAnd {
+			opts.Operator = "AND"
+		}
+
+		if opts.QueryFile != "" {
+			return runQueryFile(opts)
+		}
+		return runSearchCommand(query, opts)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
-	searchCmd.Flags().IntP("limit", "l", 5, "maximum number of results")
+	addReadOnlyFallbackFlag(searchCmd)
+	addDatabaseFlag(searchCmd)
+	searchCmd.Flags().StringArray("db", nil, "search this database, repeat to search several and merge the results by rank")
+	searchCmd.MarkFlagsMutuallyExclusive("db", "database")
+	searchCmd.Flags().IntP("limit", "l", 5, "maximum number of files, or of pages with --plain, --json-lines and --format csv, 0 for no limit")
+	searchCmd.Flags().Int("offset", 0, "number of results to skip")
+	searchCmd.Flags().Int("max-results", defaultMaxResults, "stop with a warning past this many results when --limit is 0, 0 for no cap")
+	searchCmd.Flags().Bool("relative", false, "show paths relative to the current directory")
+	searchCmd.Flags().Bool("plain", false, "print one 'path:page:snippet' line per result, without colors or grouping")
+	searchCmd.Flags().Bool("oneline", false, "print one 'path (N matches): snippet' line per file with its best snippet, without boxes")
+	searchCmd.Flags().Bool("json-lines", false, "stream one JSON object per result (NDJSON)")
+	searchCmd.Flags().String("format", formatText, "output format: text, or csv with a path,page,snippet,last_scanned header")
+	searchCmd.Flags().Int("with-pages", 0, fmt.Sprintf("embed the text of the matching page and of this many pages around it in JSON results, up to %d", maxWithPages))
+	searchCmd.Flags().StringSlice("fields", nil, "only include these comma separated fields in JSON output (fields: "+strings.Join(jsonFields, ", ")+")")
+	searchCmd.Flags().Bool("line-context", false, "show the full lines containing the match instead of a token window")
+	searchCmd.Flags().Int("snippet-before", 0, "build snippets from the page text with this many characters before the match")
+	searchCmd.Flags().Int("snippet-after", 0, "build snippets from the page text with this many characters after the match")
+	searchCmd.Flags().IntP("after-context", "A", 0, "also show this many pages after each matching page")
+	searchCmd.Flags().IntP("before-context", "B", 0, "also show this many pages before each matching page")
+	searchCmd.Flags().Int("show-context-pages", 0, "also show this many pages before and after each matching page, like -A N -B N")
+	searchCmd.Flags().Bool("group-context", false, "show the runs of consecutive matching pages of a file as a single page range, in page order (grouped output only)")
+	searchCmd.Flags().Bool("and", false, "match pages containing all of the words, taken literally instead of as FTS query syntax")
+	searchCmd.Flags().Bool("or", false, "match pages containing any of the words, taken literally instead of as FTS query syntax")
+	searchCmd.Flags().String("scope", scopePage, "where all the terms must appear: page, or document to match documents with each term on some page")
+	searchCmd.Flags().StringArray("field", nil, "only match pages where a field contains a term, as field:term (fields: filename, content)")
+	searchCmd.Flags().Float64("filename-weight", database.DefaultFilenameWeight, "ranking weight of matches in the file name, unless set with rank-weights")
+	searchCmd.Flags().Float64("content-weight", database.DefaultContentWeight, "ranking weight of matches in the page content, unless set with rank-weights")
+	searchCmd.Flags().Bool("distinct-files", false, "show only the best matching page of each file, --limit then counts files")
+	searchCmd.Flags().String("dedupe-by", dedupeNone, "collapse copies: none, or content to show files with identical content once (the most recently scanned)")
+	searchCmd.Flags().String("sort", sortRelevance, "result order: relevance, or length for the longest pages first")
+	searchCmd.Flags().Int("min-page-chars", 0, "hide pages with less than this many characters of text")
+	searchCmd.Flags().Bool("exclude-empty", false, fmt.Sprintf("hide nearly empty pages, same as --min-page-chars %d", emptyPageChars))
+	searchCmd.Flags().Float64("max-rank", 0, "drop results scoring above this bm25 score (negative, lower is more relevant, see --explain)")
+	searchCmd.Flags().Float64("min-quality", 0, fmt.Sprintf("hide pages whose extracted text looks garbled, from 0 to 1 (%.1f flags low quality)", pdf.LowQuality))
+	searchCmd.Flags().Bool("read", false, "only search files marked as read with the read command")
+	searchCmd.Flags().Bool("unread", false, "only search files not marked as read")
+	searchCmd.Flags().String("section", "", "only search the pages within an outline section whose title contains this text, outlines are read when a file is extracted (scan --force reads them for files scanned before)")
+	searchCmd.Flags().String("since", "", "only search files scanned within this long, like 24h, 7d or 2w")
+	searchCmd.Flags().String("in", "", "only search files under this directory")
+	searchCmd.Flags().String("pages", "", "only search these pages of each file: N, FROM-TO or FROM-")
+	searchCmd.Flags().String("path", "", "only search files whose path matches this glob pattern")
+	searchCmd.Flags().Bool("path-ci", caseInsensitivePaths, "match --in and --path ignoring case and accents (default on macOS and Windows)")
+	searchCmd.Flags().String("snippet-ellipsis", database.DefaultSnippetEllipsis, "text marking where snippets are cut, can be empty")
+	searchCmd.Flags().Bool("trim-snippet-whitespace", true, "drop the spaces around the ellipsis of snippets and merge repeated ones, otherwise only collapse whitespace")
+	searchCmd.Flags().Int("max-snippet-width", 0, fmt.Sprintf("wrap snippets at this many columns, 0 to fit the terminal (%d when not a terminal)", defaultSnippetWidth))
+	searchCmd.Flags().Bool("highlight-query-only", false, "highlight only the words of the query as typed, ignoring the matches marked by the index")
+	searchCmd.Flags().Bool("explain", false, "show the score of each result and where the query terms matched")
+	searchCmd.Flags().Bool("open-first", false, "open the top result at its page in the viewer ($"+viewer.EnvVar+") instead of listing results")
+	searchCmd.Flags().String("export-matches", "", "also render each matching page to a PNG image in this directory")
+	searchCmd.Flags().Float64("export-dpi", pdf.DefaultDPI, "resolution of the images of --export-matches")
+	searchCmd.Flags().String("normalize-query", normalizeOn, "remove the accents of the query like scan does for the indexed text: on, or off to match the stored text as typed")
+	searchCmd.Flags().Bool("fuzzy", false, "tolerate small typos by matching pages sharing most trigrams with the query")
+	searchCmd.Flags().Bool("exact", false, "match the whole query as a single phrase, its words contiguous and in order")
+	searchCmd.Flags().String("like", "", "find documents similar to this indexed one, by the distinctive words they share")
+	searchCmd.MarkFlagsMutuallyExclusive("and", "or")
+	searchCmd.MarkFlagsMutuallyExclusive("line-context", "snippet-before")
+	searchCmd.MarkFlagsMutuallyExclusive("line-context", "snippet-after")
+	searchCmd.MarkFlagsMutuallyExclusive("format", "plain", "json-lines", "oneline")
+	searchCmd.MarkFlagsMutuallyExclusive("oneline", "distinct-files")
+	searchCmd.MarkFlagsMutuallyExclusive("read", "unread")
+	searchCmd.MarkFlagsMutuallyExclusive("fuzzy", "and")
+	searchCmd.MarkFlagsMutuallyExclusive("fuzzy", "or")
+	for _, flag := range []string{"fuzzy", "and", "or", "like", "scope"} {
+		searchCmd.MarkFlagsMutuallyExclusive("exact", flag)
+	}
+	searchCmd.Flags().String("query-file", "", "run a search for each line of this file, skipping blank lines and # comments")
+	for _, flag := range []string{"like", "open-first"} {
+		searchCmd.MarkFlagsMutuallyExclusive("query-file", flag)
+	}
+	searchCmd.MarkFlagsMutuallyExclusive("like", "fuzzy")
+	searchCmd.MarkFlagsMutuallyExclusive("like", "and")
+	searchCmd.MarkFlagsMutuallyExclusive("like", "or")
 }
 
-func runSearchCommand(queryTerm string, limit int) error {
+// searchOptions holds the flags controlling a search and how its results are displayed
+type searchOptions struct {
+	// Databases are the databases of a federated search, given with --db
+	Databases []string
+
+	Limit  int
+	Offset int
+	// MaxResults caps the output of searches without a limit
+	MaxResults int
+	Plain      bool
+	JSONLines  bool
+	// Oneline prints a single line per file instead of the grouped boxes
+	Oneline bool
+	// Format is formatText or formatCSV
+	Format      string
+	LineContext bool
+	// SnippetBefore and SnippetAfter build snippets in Go with asymmetric
+	// windows around the match instead of the FTS snippet() function
+	SnippetBefore int
+	SnippetAfter  int
+	Fuzzy         bool
+	// Exact matches the query as a single quoted phrase
+	Exact bool
+	// NormalizeQuery is normalizeOn to fold the query like the indexed text
+	NormalizeQuery string
+	// Like is an indexed document whose distinctive words make the query
+	Like string
+	// QueryFile has the queries of a batch search, one per line
+	QueryFile string
+	// BatchQuery and BatchIndex are the query and its position in the
+	// QueryFile while running a batch, BatchQuery is empty otherwise
+	BatchQuery string
+	BatchIndex int
+	Explain    bool
+	OpenFirst  bool
+	// ExportMatches is a directory receiving an image of each matching page
+	ExportMatches string
+	ExportDPI     float64
+	// Relative displays absolute paths relative to the working directory
+	Relative bool
+
+	SnippetEllipsis string
+	// TrimSnippetWhitespace tidies the ellipsis of the snippets along with
+	// collapsing their whitespace
+	TrimSnippetWhitespace bool
+	// HighlightQueryOnly ignores the FTS highlight markers and highlights the
+	// query words in the snippet instead
+	HighlightQueryOnly bool
+	// SnippetWidth is the column at which snippets of the grouped output wrap
+	SnippetWidth int
+	// OutputFields limits the keys of each JSON result, all of them when empty
+	OutputFields []string
+	// IncludePages embeds in each JSON result the text of the matching page
+	// and of WithPages pages before and after it
+	IncludePages bool
+	WithPages    int
+	// Scope is scopePage or scopeDocument
+	Scope string
+	// Fields are "field:term" filters restricting a term to a single FTS column
+	Fields []string
+	// AfterContext and BeforeContext are the number of neighboring pages shown
+	// around each matching page, like grep's -A and -B
+	AfterContext  int
+	BeforeContext int
+	// GroupContext merges the runs of consecutive matching pages of a file
+	// into a single entry of the grouped output
+	GroupContext bool
+	// Operator joins the query terms as quoted literals when set to "AND" or
+	// "OR", otherwise the query is passed to FTS as typed
+	Operator string
+
+	// InDir and PathGlob restrict the searched files, PathCI ignores case and
+	// accents when matching them
+	InDir    string
+	PathGlob string
+	PathCI   bool
+	// FromPage and ToPage restrict the searched pages, 0 leaves a bound open
+	FromPage int
+	ToPage   int
+
+	FilenameWeight float64
+	ContentWeight  float64
+
+	// MinPageChars hides pages with less text than this
+	MinPageChars int
+	// DistinctFiles keeps only the best ranked page of each file
+	DistinctFiles bool
+	// DedupeBy is dedupeNone or dedupeContent
+	DedupeBy string
+	// Sort is sortRelevance or sortLength
+	Sort string
+	// MaxRank drops results with a weaker (higher) bm25 score, 0 disables it
+	MaxRank float64
+	// MinQuality hides pages with a lower text quality score
+	MinQuality float64
+	// OnlyRead and OnlyUnread filter files by their read mark
+	OnlyRead   bool
+	OnlyUnread bool
+	// Since keeps the files scanned within this duration of now, 0 disables it
+	Since time.Duration
+	// Section keeps the pages within outline sections with a matching title
+	Section string
+}
+
+// emptyPageChars is the page length below which --exclude-empty hides a page
+const emptyPageChars = 100
+
+// caseInsensitivePaths is true on platforms whose filesystems are usually case-insensitive
+var caseInsensitivePaths = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+
+// searchFields maps the field names accepted by --field to FTS columns
+var searchFields = map[string]string{
+	"filename": "filename",
+	"content":  "content_idx",
+}
+
+// buildMatchQuery builds the FTS5 MATCH expression for the user query
+func buildMatchQuery(queryTerm string, opts searchOptions) (string, error) {
+	var matchQuery string
+	switch {
+	case opts.Fuzzy:
+		matchQuery = fuzzyMatchQuery(queryTerm)
+	case opts.Exact:
+		matchQuery = quoteFTSTerm(strings.Join(strings.Fields(queryTerm), " "))
+	case opts.Scope == scopeDocument:
+		// Pages matching any term are shown, documentTerms keeps the documents
+		// matching all of them
+		matchQuery = strings.Join(documentTerms(queryTerm), " OR ")
+	case opts.Operator == "":
+		matchQuery = quoteDottedTerms(queryTerm)
+	default:
+		var terms []string
+		for _, word := range strings.Fields(queryTerm) {
+			terms = append(terms, quoteFTSTerm(word))
+		}
+		matchQuery = strings.Join(terms, " "+opts.Operator+" ")
+	}
+
+	if len(opts.Fields) == 0 {
+		return matchQuery, nil
+	}
+
+	// Field filters are combined with the main query using column filters
+	parts := []string{"(" + matchQuery + ")"}
+	for _, field := range opts.Fields {
+		name, term, ok := strings.Cut(field, ":")
+		column, known := searchFields[name]
+		if !ok || !known || term == "" {
+			return "", fmt.Errorf("invalid --field %q, expected filename:term or content:term", field)
+		}
+		parts = append(parts, column+" : "+quoteFTSTerm(term))
+	}
+	return strings.Join(parts, " AND "), nil
+}
+
+// defaultMatchQuery builds the FTS5 MATCH expression of a query like search
+// does with its default flags, for the commands without them
+func defaultMatchQuery(queryTerm string) (string, error) {
+	return buildMatchQuery(pdf.NormalizeQuery(queryTerm), searchOptions{})
+}
+
+// parseSince parses a --since duration, a Go duration or a number of days
+// ("7d") or weeks ("2w")
+func parseSince(value string) (time.Duration, error) {
+	var since time.Duration
+	var err error
+	switch unit := value[len(value)-1:]; unit {
+	case "d", "w":
+		var count float64
+		count, err = strconv.ParseFloat(value[:len(value)-1], 64)
+		since = time.Duration(count * float64(24*time.Hour))
+		if unit == "w" {
+			since *= 7
+		}
+	default:
+		since, err = time.ParseDuration(value)
+	}
+	if err != nil || since <= 0 {
+		return 0, fmt.Errorf("invalid --since %q, expected a positive duration like 24h, 7d or 2w", value)
+	}
+	return since, nil
+}
+
+// parsePageRange parses a --pages range: a single page "5", a closed range
+// "1-10" or an open-ended one "20-". An open end is returned as 0.
+func parsePageRange(value string) (from, to int, err error) {
+	fromText, toText, isRange := strings.Cut(value, "-")
+	from, err = strconv.Atoi(strings.TrimSpace(fromText))
+	if err != nil || from < 1 {
+		return 0, 0, fmt.Errorf("invalid --pages %q, expected N, FROM-TO or FROM- with pages starting at 1", value)
+	}
+	if !isRange {
+		return from, from, nil
+	}
+	if strings.TrimSpace(toText) == "" {
+		return from, 0, nil
+	}
+
+	to, err = strconv.Atoi(strings.TrimSpace(toText))
+	if err != nil || to < 1 {
+		return 0, 0, fmt.Errorf("invalid --pages %q, expected N, FROM-TO or FROM- with pages starting at 1", value)
+	}
+	if to < from {
+		return 0, 0, fmt.Errorf("invalid --pages %q, the range ends before it starts", value)
+	}
+	return from, to, nil
+}
+
+// Values of --dedupe-by
+const (
+	dedupeNone    = "none"
+	dedupeContent = "content"
+)
+
+// Values of --sort
+const (
+	sortRelevance = "relevance"
+	sortLength    = "length"
+)
+
+// Values of --scope
+const (
+	scopePage     = "page"
+	scopeDocument = "document"
+)
+
+// documentTerms returns the quoted terms of the query that must each match
+// some page of a document with --scope document
+func documentTerms(queryTerm string) []string {
+	var terms []string
+	for _, word := range strings.Fields(queryTerm) {
+		terms = append(terms, quoteFTSTerm(word))
+	}
+	return terms
+}
+
+// quoteFTSTerm escapes a term as an FTS5 string so operators and special
+// characters in it are matched literally
+func quoteFTSTerm(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
+
+// dottedTerm matches a bare word joining letters and digits with dots, dashes
+// or slashes, like a version, date or identifier, with an optional prefix star
+var dottedTerm = regexp.MustCompile(`^([\pL\pN_]+(?:[./-][\pL\pN_]+)+)(\*?)$`)
+
+// quoteDottedTerms quotes the words of a full-text query like "v1.2",
+// "2023-01-15" or "amd64/linux" outside of quoted phrases. The trigram index
+// stores them as written but FTS5 rejects the separators in bare words.
+func quoteDottedTerms(query string) string {
+	// Even parts are outside of quoted phrases
+	parts := strings.Split(query, `"`)
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = bareWord.ReplaceAllStringFunc(parts[i], func(word string) string {
+			m := dottedTerm.FindStringSubmatch(word)
+			if m == nil {
+				return word
+			}
+			return quoteFTSTerm(m[1]) + m[2]
+		})
+	}
+	return strings.Join(parts, `"`)
+}
+
+// bareWord matches the words of a query between spaces and parentheses
+var bareWord = regexp.MustCompile(`[^\s()]+`)
+
+func runSearchCommand(queryTerm string, opts searchOptions) error {
+	if opts.NormalizeQuery == normalizeOn {
+		queryTerm = pdf.NormalizeQuery(queryTerm)
+		fields := make([]string, len(opts.Fields))
+		for i, field := range opts.Fields {
+			fields[i] = pdf.NormalizeQuery(field)
+		}
+		opts.Fields = fields
+	}
+
+	if opts.Like != "" {
+		opts.Like = filepath.Clean(opts.Like)
+		var err error
+		queryTerm, err = likeQuery(opts.Like)
+		if err != nil {
+			return err
+		}
+	}
+
+	matchQuery, err := buildMatchQuery(queryTerm, opts)
+	if err != nil {
+		return err
+	}
 	if cfg.Verbose {
-		log.Printf("Search for: '%s', limit: %d", queryTerm, limit)
+		log.Printf("Search for: '%s' (match: '%s'), limit: %d", queryTerm, matchQuery, opts.Limit)
 	}
 
-	searchResults, err := db.Search(queryTerm, limit)
+	dbOpts := database.SearchOptions{
+		Limit:           opts.Limit,
+		Offset:          opts.Offset,
+		SnippetEllipsis: opts.SnippetEllipsis,
+		PathGlob:        opts.PathGlob,
+		FoldPaths:       opts.PathCI,
+		FilenameWeight:  opts.FilenameWeight,
+		ContentWeight:   opts.ContentWeight,
+		MinPageChars:    opts.MinPageChars,
+		FromPage:        opts.FromPage,
+		ToPage:          opts.ToPage,
+		DistinctFiles:   opts.DistinctFiles,
+		SortByLength:    opts.Sort == sortLength,
+		DedupeByContent: opts.DedupeBy == dedupeContent,
+		MinQuality:      opts.MinQuality,
+		MaxRank:         opts.MaxRank,
+		OnlyRead:        opts.OnlyRead,
+		OnlyUnread:      opts.OnlyUnread,
+		ExcludePath:     opts.Like,
+		Section:         opts.Section,
+	}
+	if opts.Since > 0 {
+		dbOpts.ScannedSince = time.Now().Add(-opts.Since)
+	}
+	if opts.Scope == scopeDocument {
+		dbOpts.DocumentTerms = documentTerms(queryTerm)
+	}
+	if opts.InDir != "" {
+		dbOpts.InDir = filepath.Clean(opts.InDir)
+	}
+
+	if opts.OpenFirst {
+		return openFirstResult(matchQuery, dbOpts)
+	}
+
+	// An unlimited search fetches one result past the cap to detect a flood
+	capped := opts.Limit <= 0 && opts.MaxResults > 0
+	if capped {
+		dbOpts.Limit = opts.MaxResults + 1
+	}
+
+	if opts.ExportMatches != "" {
+		// Export the pages the output shows, with its meaning of the limit
+		exportOpts := dbOpts
+		exportOpts.LimitFiles = !opts.JSONLines && !opts.Plain && opts.Format == formatText
+		if err := exportMatches(matchQuery, queryTerm, exportOpts, opts, capped); err != nil {
+			return err
+		}
+	}
+
+	if opts.JSONLines {
+		return streamJSONLines(matchQuery, queryTerm, dbOpts, opts, capped)
+	}
+
+	// The grouped output lists files, so the limit counts files there
+	dbOpts.LimitFiles = !opts.Plain && opts.Format == formatText
+
+	if opts.Plain {
+		return printPlainResults(matchQuery, queryTerm, dbOpts, opts, capped)
+	}
+
+	if opts.Format == formatCSV {
+		return printCSVResults(matchQuery, queryTerm, dbOpts, opts, capped)
+	}
+
+	if opts.Oneline {
+		return printOnelineResults(matchQuery, queryTerm, dbOpts, opts, capped)
+	}
+
+	return printGroupedResults(matchQuery, queryTerm, dbOpts, opts, capped)
+}
+
+// onelineSnippetCells is the display width of the snippet of --oneline
+const onelineSnippetCells = 80
+
+// printOnelineResults prints one "path (N matches): snippet" line per file,
+// with the number of matching pages and the snippet of the best one. Results
+// come ordered by file with the best page first.
+func printOnelineResults(matchQuery, queryTerm string, dbOpts database.SearchOptions, opts searchOptions, capped bool) error {
+	var fileResults []database.SearchResult
+	flush := func() {
+		if len(fileResults) == 0 {
+			return
+		}
+		pages := make(map[int]bool)
+		for _, result := range fileResults {
+			pages[result.PageNum] = true
+		}
+
+		best := fileResults[0]
+		snippet := cleanSnippet(best.Snippet, opts)
+		snippet = stripHighlightMarkers(util.FitSnippet(snippet, onelineSnippetCells))
+		path := best.Path
+		if opts.Relative {
+			path = util.RelativePath(path)
+		}
+		fmt.Printf("%s (%d matches): %s\n", path, len(pages), snippet)
+		fileResults = fileResults[:0]
+	}
+
+	truncated, err := eachResult(matchQuery, queryTerm, dbOpts, opts, capped, func(result database.SearchResult) error {
+		if len(fileResults) > 0 && !sameFile(fileResults[0], result) {
+			flush()
+		}
+		fileResults = append(fileResults, result)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("search query failed: %w", err)
+		return err
+	}
+	flush()
+	if truncated {
+		warnResultCap(opts.MaxResults)
 	}
+	return nil
+}
 
+// printGroupedResults prints a box per file with its matching pages. Results
+// come ordered by file, so each box is printed as soon as the rows of its
+// file have been read.
+func printGroupedResults(matchQuery, queryTerm string, dbOpts database.SearchOptions, opts searchOptions, capped bool) error {
 	// Define lipgloss styles
 	headerStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("13")).
@@ -86,82 +738,227 @@ func runSearchCommand(queryTerm string, limit int) error {
 		Width(5).
 		Bold(true)
 
-	// Group results by file path while maintaining order
-	type FileResult struct {
-		Path  string
-		Pages []string
+	explainStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		PaddingLeft(6).
+		Width(opts.SnippetWidth + 6)
+
+	contextPageStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Width(5)
+
+	volumes, err := searchVolumes()
+	if err != nil {
+		return err
 	}
 
-	var groupedResults []FileResult
-	resultMap := make(map[string]*FileResult)
+	// renderFile formats the box of a file from all its matching pages, which
+	// are never repeated as context pages
+	renderFile := func(path string, fileResults []database.SearchResult) (string, error) {
+		fileDB := resultDB(fileResults[0])
+		matchedPages := make(map[int]bool)
+		for _, result := range fileResults {
+			matchedPages[result.PageNum] = true
+		}
+		shownContext := make(map[int]bool)
+
+		// renderContextPages fetches and formats the neighboring pages in the
+		// given range that are neither matches nor already shown
+		renderContextPages := func(fromPage, toPage int) ([]string, error) {
+			if fromPage > toPage {
+				return nil, nil
+			}
+			pages, err := fileDB.GetPageContent(path, fromPage, toPage)
+			if err != nil {
+				return nil, fmt.Errorf("fetching context pages: %w", err)
+			}
+
+			var rendered []string
+			for _, page := range pages {
+				if matchedPages[page.PageNum] || shownContext[page.PageNum] {
+					continue
+				}
+				shownContext[page.PageNum] = true
 
-	for _, result := range searchResults {
-		if _, exists := resultMap[result.Path]; !exists {
-			fileResult := FileResult{Path: result.Path, Pages: []string{}}
-			groupedResults = append(groupedResults, fileResult)
-			resultMap[result.Path] = &groupedResults[len(groupedResults)-1]
+				rendered = append(rendered, lipgloss.JoinHorizontal(lipgloss.Left,
+					contextPageStyle.Render("p."+database.PrintedPage(page.PageNum, fileResults[0].PageOffset)),
+					" ",
+					lipgloss.NewStyle().
+						Width(opts.SnippetWidth).
+						Render(contextPageStyle.UnsetWidth().Render("(context)")+" "+
+							highlightMatches(util.FitSnippet(page.Content, contextSnippetLen), queryTerm)),
+				))
+			}
+			return rendered, nil
 		}
 
-		// Process and highlight snippet
-		snippet := strings.ReplaceAll(result.Snippet, "\n", " ")
-		snippet = spaceNormalizer.ReplaceAllString(snippet, " ")
-		highlightedSnippet := highlightMatches(snippet, queryTerm)
+		// Without --group-context each matching page is shown on its own
+		runs := make([][]database.SearchResult, len(fileResults))
+		for i := range fileResults {
+			runs[i] = fileResults[i : i+1]
+		}
+		if opts.GroupContext {
+			runs = pageRuns(fileResults)
+		}
 
-		// Format snippet with page number
-		resultMap[result.Path].Pages = append(resultMap[result.Path].Pages,
-			lipgloss.JoinHorizontal(lipgloss.Left,
-				pageStyle.Render(fmt.Sprintf("p.%d", result.PageNum)),
+		var pages []string
+		for _, run := range runs {
+			first, last := run[0], run[len(run)-1]
+			before, err := renderContextPages(max(1, first.PageNum-opts.BeforeContext), first.PageNum-1)
+			if err != nil {
+				return "", err
+			}
+			pages = append(pages, before...)
+
+			// Process and highlight snippet, line snippets keep their line breaks
+			snippets := make([]string, len(run))
+			for i, result := range run {
+				snippets[i] = result.Snippet
+			}
+			var snippet string
+			if opts.LineContext {
+				snippet = strings.Join(snippets, "\n")
+			} else {
+				for i := range snippets {
+					snippets[i] = cleanSnippet(snippets[i], opts)
+				}
+				snippet = strings.Join(snippets, " ")
+				if e := opts.SnippetEllipsis; e != "" {
+					// Adjacent snippets share the ellipsis between them
+					snippet = strings.ReplaceAll(snippet, e+" "+e, e)
+				}
+				snippet = util.FitSnippet(snippet, snippetRows*opts.SnippetWidth)
+			}
+			if opts.HighlightQueryOnly {
+				snippet = stripHighlightMarkers(snippet)
+			}
+			highlightedSnippet := highlightMatches(snippet, queryTerm)
+			if volume, ok := volumes[path]; ok {
+				highlightedSnippet = pathStyle.Render("(document "+
+					pageSpan(volume.DocumentPage(first.PageNum), volume.DocumentPage(last.PageNum))+")") +
+					" " + highlightedSnippet
+			}
+			if first.PageOffset != 0 {
+				// The PDF page is the one to open or export
+				highlightedSnippet = pathStyle.Render("(PDF "+pageSpan(first.PageNum, last.PageNum)+")") +
+					" " + highlightedSnippet
+			}
+
+			// Format snippet with page number
+			label := "p." + database.PrintedPage(first.PageNum, first.PageOffset)
+			if last.PageNum != first.PageNum {
+				label += "-" + database.PrintedPage(last.PageNum, last.PageOffset)
+			}
+			page := lipgloss.JoinHorizontal(lipgloss.Left,
+				pageStyle.Width(max(5, len(label))).Render(label),
 				" ",
 				lipgloss.NewStyle().
-					Width(90).
+					Width(opts.SnippetWidth).
 					Render(highlightedSnippet),
-			),
-		)
-	}
+			)
 
-	var results []string
-	var resultsFound int
-
-	// Header
-	fmt.Println(headerStyle.Render("Search Results") + " for " + queryStyle.Render("'"+queryTerm+"'"))
+			if opts.Explain {
+				for _, result := range run {
+					explanation, err := fileDB.ExplainMatch(matchQuery, path, result.PageNum, dbOpts)
+					if err != nil {
+						return "", err
+					}
+					page += "\n" + explainStyle.Render(formatExplanation(explanation))
+				}
+			}
+			pages = append(pages, page)
 
-	for _, fileResult := range groupedResults {
-		resultsFound++
+			after, err := renderContextPages(last.PageNum+1, last.PageNum+opts.AfterContext)
+			if err != nil {
+				return "", err
+			}
+			pages = append(pages, after...)
+		}
 
 		// Format filename
-		base := filepath.Base(fileResult.Path)
-		maxBaseLen := 82 // Reduced to make room for page number
+		base := filepath.Base(path)
+		maxBaseLen := opts.SnippetWidth - 8 // Reduced to make room for page number
 		if len(base) > maxBaseLen {
 			base = base[:maxBaseLen-3] + "..."
 		}
 
+		displayPath := path
+		if opts.Relative {
+			displayPath = util.RelativePath(displayPath)
+		}
+
 		baseWithPath := fmt.Sprintf(
 			"%s\n%s",
 			fileStyle.Render(base),
-			pathStyle.Render(filepath.Dir(fileResult.Path)+"/"),
+			pathStyle.Render(filepath.Dir(displayPath)+"/"),
 		)
-
-		// Combine snippets
-		combinedSnippets := strings.Join(fileResult.Pages, "\n\n")
+		if volume, ok := volumes[path]; ok {
+			baseWithPath += "\n" + pathStyle.Render(fmt.Sprintf("Volume %d of %s", volume.Number, volume.Document))
+		}
+		if dbPath := fileResults[0].Database; dbPath != "" {
+			baseWithPath += "\n" + pathStyle.Render("in "+dbPath)
+		}
 
 		// Build result content
 		resultContent := lipgloss.JoinVertical(
 			lipgloss.Left,
 			baseWithPath,
-			snippetStyle.Render(combinedSnippets),
+			snippetStyle.Render(strings.Join(pages, "\n\n")),
 		)
+		return resultBoxStyle.Render(resultContent), nil
+	}
 
-		results = append(results, resultBoxStyle.Render(resultContent))
+	// Header
+	fmt.Println(headerStyle.Render("Search Results") + " for " + queryStyle.Render("'"+queryTerm+"'"))
+
+	var resultsFound int
+	var fileResults []database.SearchResult
+	flush := func() error {
+		if len(fileResults) == 0 {
+			return nil
+		}
+		box, err := renderFile(fileResults[0].Path, fileResults)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSpace(box))
+		resultsFound++
+		fileResults = fileResults[:0]
+		return nil
 	}
 
-	// Display all results
-	for _, result := range results {
-		fmt.Println(strings.TrimSpace(result))
+	truncated, err := eachResult(matchQuery, queryTerm, dbOpts, opts, capped, func(result database.SearchResult) error {
+		if len(fileResults) > 0 && !sameFile(fileResults[0], result) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		fileResults = append(fileResults, result)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if truncated {
+		defer warnResultCap(opts.MaxResults)
 	}
 
 	// Summary
 	if resultsFound == 0 {
 		fmt.Println(noResultsStyle.Render("No results found."))
+		// The query of --like is made of words of an indexed document
+		if !opts.Fuzzy && opts.Like == "" {
+			suggestions, err := suggestTerms(queryTerm, maxSuggestions)
+			if err != nil && cfg.Verbose {
+				log.Printf("Warning: Could not compute suggestions: %v", err)
+			}
+			if len(suggestions) > 0 {
+				fmt.Println("Did you mean: " + queryStyle.Render(strings.Join(suggestions, ", ")) + "?")
+			}
+		}
 	} else {
 		fmt.Println(countStyle.Render(fmt.Sprintf("Found %d result(s).", resultsFound)))
 	}
@@ -170,6 +967,494 @@ func runSearchCommand(queryTerm string, limit int) error {
 	return nil
 }
 
+// pageRuns splits the matching pages of a file into runs of consecutive pages,
+// in page order, for --group-context. Segments of the same page stay together.
+func pageRuns(results []database.SearchResult) [][]database.SearchResult {
+	sorted := slices.Clone(results)
+	slices.SortStableFunc(sorted, func(a, b database.SearchResult) int {
+		return a.PageNum - b.PageNum
+	})
+
+	var runs [][]database.SearchResult
+	for i, result := range sorted {
+		if i > 0 && result.PageNum-sorted[i-1].PageNum <= 1 {
+			runs[len(runs)-1] = append(runs[len(runs)-1], result)
+			continue
+		}
+		runs = append(runs, []database.SearchResult{result})
+	}
+	return runs
+}
+
+// pageSpan formats a page ("page 3") or, when they differ, a range of pages
+// ("pages 3-5")
+func pageSpan(first, last int) string {
+	if first == last {
+		return fmt.Sprintf("page %d", first)
+	}
+	return fmt.Sprintf("pages %d-%d", first, last)
+}
+
+// openFirstResult opens the top ranked result in the viewer without listing the results
+func openFirstResult(matchQuery string, dbOpts database.SearchOptions) error {
+	dbOpts.Limit = 1
+	var results []database.SearchResult
+	err := searchEach(matchQuery, dbOpts, func(result database.SearchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("search query failed: %w", err)
+	}
+	if len(results) == 0 {
+		return errNoResults
+	}
+
+	top := results[0]
+	if cfg.Verbose {
+		log.Printf("Opening %s at page %d (printed page %s)", top.Path, top.PageNum, database.PrintedPage(top.PageNum, top.PageOffset))
+	}
+	return viewer.Open(top.Path, top.PageNum)
+}
+
+// formatExplanation formats the diagnostic block shown by --explain
+func formatExplanation(explanation *database.MatchExplanation) string {
+	lines := []string{fmt.Sprintf("score: %.3f (bm25, lower is better)", explanation.Score)}
+	for _, match := range explanation.Matches {
+		position := ""
+		if match.Column == "content" && explanation.ContentLength > 0 {
+			position = fmt.Sprintf(", first at char %d (%d%% into the page)",
+				match.FirstOffset, match.FirstOffset*100/explanation.ContentLength)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %q ×%d%s", match.Column, match.Term, match.Count, position))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applySnippetMode replaces the FTS snippet of a result with the one built
+// from the page text when --line-context or the snippet windows are set
+func applySnippetMode(result *database.SearchResult, queryTerm string, opts searchOptions) error {
+	switch {
+	case opts.LineContext:
+		return applyLineContext(result, queryTerm)
+	case opts.SnippetBefore > 0 || opts.SnippetAfter > 0:
+		return applyWindowSnippet(result, queryTerm, opts.SnippetBefore, opts.SnippetAfter, opts.SnippetEllipsis)
+	}
+	return nil
+}
+
+// applyWindowSnippet replaces the snippet of a result with a window of the
+// page text around the first match, keeping the FTS snippet if none is found
+func applyWindowSnippet(result *database.SearchResult, queryTerm string, before, after int, ellipsis string) error {
+	text, err := resultDB(*result).GetPageText(result.Path, result.PageNum)
+	if err != nil {
+		return fmt.Errorf("fetching page content: %w", err)
+	}
+	if snippet := windowSnippet(text, queryTerm, before, after, ellipsis); snippet != "" {
+		result.Snippet = snippet
+	}
+	return nil
+}
+
+// applyLineContext replaces the snippet of a result with the lines of the page
+// containing the query terms, keeping the FTS snippet if none is found
+func applyLineContext(result *database.SearchResult, queryTerm string) error {
+	text, err := resultDB(*result).GetPageText(result.Path, result.PageNum)
+	if err != nil {
+		return fmt.Errorf("fetching page content: %w", err)
+	}
+	if snippet := lineSnippet(text, queryTerm, maxSnippetLines); snippet != "" {
+		result.Snippet = snippet
+	}
+	return nil
+}
+
+// jsonResult is the JSON representation of a search result
+type jsonResult struct {
+	// Query is the query of the result in a --query-file batch
+	Query       string  `json:"query,omitempty"`
+	Path        string  `json:"path"`
+	Page        int     `json:"page"`
+	Snippet     string  `json:"snippet"`
+	LastScanned string  `json:"last_scanned"`
+	Score       float64 `json:"score"`
+	// Position is the fraction of the page before the first match
+	Position *float64 `json:"position,omitempty"`
+	// Length is the number of characters of text in the page
+	Length int `json:"length"`
+	// Database is the database of the result when searching several
+	Database string `json:"database,omitempty"`
+	// PrintedPage is the page number printed in the file, for files with a page offset
+	PrintedPage string `json:"printed_page,omitempty"`
+	// Document fields are set for files grouped as volumes of a document
+	Document     string `json:"document,omitempty"`
+	Volume       int    `json:"volume,omitempty"`
+	DocumentPage int    `json:"document_page,omitempty"`
+	// Pages are the matching page and its neighbors with --with-pages
+	Pages []jsonPage `json:"pages,omitempty"`
+}
+
+// jsonPage is the text of a page embedded in a JSON result
+type jsonPage struct {
+	Page    int    `json:"page"`
+	Content string `json:"content"`
+}
+
+// maxWithPages bounds --with-pages, each page adds its whole text to every result
+const maxWithPages = 5
+
+// newJSONResult converts a search result to its JSON form, without highlight markers
+func newJSONResult(result database.SearchResult, volumes map[string]database.Volume) jsonResult {
+	jr := jsonResult{
+		Path:        result.Path,
+		Page:        result.PageNum,
+		Snippet:     stripHighlightMarkers(result.Snippet),
+		LastScanned: result.LastScanned,
+		Score:       result.Score,
+		Length:      result.Length,
+		Database:    result.Database,
+	}
+	if result.Position >= 0 {
+		jr.Position = &result.Position
+	}
+	if result.PageOffset != 0 {
+		jr.PrintedPage = database.PrintedPage(result.PageNum, result.PageOffset)
+	}
+	if volume, ok := volumes[result.Path]; ok {
+		jr.Document = volume.Document
+		jr.Volume = volume.Number
+		jr.DocumentPage = volume.DocumentPage(result.PageNum)
+	}
+	return jr
+}
+
+// jsonFields are the keys of a JSON result that can be selected with --fields
+var jsonFields = []string{"query", "path", "page", "snippet", "last_scanned", "score", "position", "length", "database", "printed_page", "document", "volume", "document_page", "pages"}
+
+// validateOutputFields checks that every selected field is one of the known
+// JSON keys
+func validateOutputFields(fields, known []string) error {
+	for _, field := range fields {
+		if !slices.Contains(known, field) {
+			return fmt.Errorf("unknown field %q, expected one of: %s", field, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// selectFields returns a JSON result or document with only the given keys, or
+// unchanged when no fields are selected
+func selectFields(jr any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return jr, nil
+	}
+
+	data, err := json.Marshal(jr)
+	if err != nil {
+		return nil, fmt.Errorf("encoding result: %w", err)
+	}
+	var all map[string]any
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("decoding result: %w", err)
+	}
+
+	selected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		// Omitted volume fields stay omitted
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
+
+// streamJSONLines writes one JSON object per result as rows are read from the
+// database, so large result sets are never held in memory
+func streamJSONLines(matchQuery, queryTerm string, dbOpts database.SearchOptions, opts searchOptions, capped bool) error {
+	volumes, err := searchVolumes()
+	if err != nil {
+		return err
+	}
+
+	dbOpts.PositionTerms = util.QueryWords(queryTerm)
+
+	encoder := json.NewEncoder(os.Stdout)
+	truncated, err := eachResult(matchQuery, queryTerm, dbOpts, opts, capped, func(result database.SearchResult) error {
+		jr := newJSONResult(result, volumes)
+		jr.Query = opts.BatchQuery
+		if opts.IncludePages {
+			pages, err := resultDB(result).GetPageContent(result.Path, max(1, result.PageNum-opts.WithPages), result.PageNum+opts.WithPages)
+			if err != nil {
+				return fmt.Errorf("fetching pages around the match: %w", err)
+			}
+			jr.Pages = make([]jsonPage, len(pages))
+			for i, page := range pages {
+				jr.Pages[i] = jsonPage{Page: page.PageNum, Content: page.Content}
+			}
+		}
+		output, err := selectFields(jr, opts.OutputFields)
+		if err != nil {
+			return err
+		}
+		return encoder.Encode(output)
+	})
+	if truncated {
+		warnResultCap(opts.MaxResults)
+	}
+	return err
+}
+
+// eachResult runs the search and calls fn with each result as batches of rows
+// are read, after applying the snippet mode, so output appears progressively
+// and large result sets are never held in memory. fn may query the database. With capped it stops past
+// opts.MaxResults results, or files when the limit counts files, and reports
+// that the output was truncated.
+func eachResult(matchQuery, queryTerm string, dbOpts database.SearchOptions, opts searchOptions, capped bool, fn func(database.SearchResult) error) (bool, error) {
+	count := 0
+	var last database.SearchResult
+	err := searchEach(matchQuery, dbOpts, func(result database.SearchResult) error {
+		// Results of the same file are consecutive when the limit counts files
+		if !dbOpts.LimitFiles || count == 0 || !sameFile(result, last) {
+			count++
+			last = result
+		}
+		if capped && count > opts.MaxResults {
+			return errResultCap
+		}
+		if err := applySnippetMode(&result, queryTerm, opts); err != nil {
+			return err
+		}
+		return fn(result)
+	})
+	if errors.Is(err, errResultCap) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("search query failed: %w", err)
+	}
+	return false, nil
+}
+
+// defaultMaxResults caps the results of searches with --limit 0
+const defaultMaxResults = 10000
+
+// errResultCap stops streaming results past --max-results
+var errResultCap = errors.New("result cap reached")
+
+// warnResultCap tells that an unlimited search was truncated by --max-results
+func warnResultCap(max int) {
+	fmt.Fprintf(os.Stderr, "Warning: more than %d results, output truncated. Refine the query or raise --max-results.\n", max)
+}
+
+// printPlainResults prints results in a grep-like "path:page:snippet" format,
+// one per line and without highlight markers
+func printPlainResults(matchQuery, queryTerm string, dbOpts database.SearchOptions, opts searchOptions, capped bool) error {
+	truncated, err := eachResult(matchQuery, queryTerm, dbOpts, opts, capped, func(result database.SearchResult) error {
+		snippet := cleanSnippet(stripHighlightMarkers(result.Snippet), opts)
+		path := result.Path
+		if opts.Relative {
+			path = util.RelativePath(path)
+		}
+		fmt.Printf("%s:%d:%s\n", path, result.PageNum, snippet)
+		return nil
+	})
+	if truncated {
+		warnResultCap(opts.MaxResults)
+	}
+	return err
+}
+
+// Values of --normalize-query
+const (
+	normalizeOn  = "on"
+	normalizeOff = "off"
+)
+
+// Values of --format
+const (
+	formatText = "text"
+	formatCSV  = "csv"
+)
+
+// printCSVResults writes results as CSV with a header row, without highlight
+// markers. Snippets keep their line breaks inside quoted fields.
+func printCSVResults(matchQuery, queryTerm string, dbOpts database.SearchOptions, opts searchOptions, capped bool) error {
+	w := csv.NewWriter(os.Stdout)
+	// A --query-file batch writes a single header and a leading query column
+	header := []string{"path", "page", "snippet", "last_scanned"}
+	if opts.BatchQuery != "" {
+		header = append([]string{"query"}, header...)
+	}
+	if opts.BatchIndex == 0 {
+		if err := w.Write(header); err != nil {
+			return fmt.Errorf("writing CSV: %w", err)
+		}
+	}
+	truncated, err := eachResult(matchQuery, queryTerm, dbOpts, opts, capped, func(result database.SearchResult) error {
+		path := result.Path
+		if opts.Relative {
+			path = util.RelativePath(path)
+		}
+		record := []string{path, strconv.Itoa(result.PageNum), stripHighlightMarkers(result.Snippet), result.LastScanned}
+		if opts.BatchQuery != "" {
+			record = append([]string{opts.BatchQuery}, record...)
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("writing CSV: %w", err)
+		}
+		// Flush each record so rows appear as they are read
+		w.Flush()
+		return w.Error()
+	})
+	if truncated {
+		warnResultCap(opts.MaxResults)
+	}
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("writing CSV: %w", err)
+	}
+	return nil
+}
+
+// stripHighlightMarkers removes the FTS [HL] and [/HL] markers from a snippet
+func stripHighlightMarkers(snippet string) string {
+	return strings.NewReplacer("[HL]", "", "[/HL]", "").Replace(snippet)
+}
+
+// maxSuggestions is the number of "did you mean" suggestions shown per query word
+const maxSuggestions = 3
+
+// cleanSnippet collapses the whitespace of a snippet, tidying its ellipsis
+// unless --trim-snippet-whitespace is off
+func cleanSnippet(snippet string, opts searchOptions) string {
+	if !opts.TrimSnippetWhitespace {
+		return util.CleanSnippet(snippet, "")
+	}
+	return util.CleanSnippet(snippet, opts.SnippetEllipsis)
+}
+
+// contextSnippetLen is the number of terminal cells shown for context pages
+const contextSnippetLen = 200
+
+// snippetRows caps the number of wrapped lines of a single snippet, very long
+// snippets with wide characters otherwise break lipgloss width calculations
+const snippetRows = 4
+
+// Snippet widths of the grouped output: the default when the output isn't a
+// terminal and the minimum on narrow terminals
+const (
+	defaultSnippetWidth = 90
+	minSnippetWidth     = 20
+)
+
+// resultBoxOverhead is the width the result box adds around a snippet: the
+// borders, the padding and the page number column
+const resultBoxOverhead = 10
+
+// terminalSnippetWidth returns the snippet width filling the terminal, or
+// defaultSnippetWidth when the output isn't a terminal
+func terminalSnippetWidth() int {
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil || width <= 0 {
+		return defaultSnippetWidth
+	}
+	return max(minSnippetWidth, width-resultBoxOverhead)
+}
+
+// maxSnippetLines is the maximum number of matching lines shown in line-context mode
+const maxSnippetLines = 3
+
+// lineSnippet returns up to maxLines lines of text containing any of the query
+// terms, with each match wrapped in the same [HL] markers used by FTS snippets.
+// It returns an empty string if no line contains a query term.
+func lineSnippet(text, queryTerm string, maxLines int) string {
+	termsRe := queryTermsRegexp(queryTerm)
+	if termsRe == nil {
+		return ""
+	}
+
+	var matched []string
+	for _, line := range strings.Split(text, "\n") {
+		if termsRe.MatchString(line) {
+			matched = append(matched, termsRe.ReplaceAllString(line, "[HL]$0[/HL]"))
+			if len(matched) == maxLines {
+				break
+			}
+		}
+	}
+
+	return strings.Join(matched, "\n")
+}
+
+// windowSnippet returns the text around the first match of a query term, with
+// up to before characters before it and after characters after it, cut at word
+// boundaries. Matches are wrapped in [HL] markers and cuts marked with the
+// ellipsis. It returns an empty string if the text doesn't contain a term.
+func windowSnippet(text, queryTerm string, before, after int, ellipsis string) string {
+	termsRe := queryTermsRegexp(queryTerm)
+	if termsRe == nil {
+		return ""
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	loc := termsRe.FindStringIndex(text)
+	if loc == nil {
+		return ""
+	}
+
+	// Move by runes from the match, then back to the closest word boundary
+	start := loc[0]
+	for n := 0; n < before && start > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	if start > 0 {
+		if space := strings.IndexByte(text[start:loc[0]], ' '); space >= 0 {
+			start += space + 1
+		} else {
+			start = loc[0]
+		}
+	}
+
+	end := loc[1]
+	for n := 0; n < after && end < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	if end < len(text) {
+		if space := strings.LastIndexByte(text[loc[1]:end], ' '); space >= 0 {
+			end = loc[1] + space
+		} else {
+			end = loc[1]
+		}
+	}
+
+	snippet := termsRe.ReplaceAllString(text[start:end], "[HL]$0[/HL]")
+	if start > 0 {
+		snippet = ellipsis + snippet
+	}
+	if end < len(text) {
+		snippet += ellipsis
+	}
+	return snippet
+}
+
+// queryTermsRegexp returns a case-insensitive regexp matching any word of the
+// query, ignoring FTS operators, or nil if the query has no words
+func queryTermsRegexp(queryTerm string) *regexp.Regexp {
+	var terms []string
+	for _, word := range util.QueryWords(queryTerm) {
+		terms = append(terms, regexp.QuoteMeta(word))
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+}
+
 // highlightMatches enhances the snippet by highlighting search terms
 func highlightMatches(snippet, queryTerm string) string {
 	// highlightColor := color.New(color.BgHiWhite, color.FgHiBlack, color.Bold)
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+)
+
+func TestBuildMatchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		opts  searchOptions
+		want  string
+	}{
+		{
+			name:  "raw query",
+			query: `neural NEAR(network model)`,
+			want:  `neural NEAR(network model)`,
+		},
+		{
+			name:  "and",
+			query: "neural  network",
+			opts:  searchOptions{Operator: "AND"},
+			want:  `"neural" AND "network"`,
+		},
+		{
+			name:  "or",
+			query: "neural network",
+			opts:  searchOptions{Operator: "OR"},
+			want:  `"neural" OR "network"`,
+		},
+		{
+			name:  "default keeps operators",
+			query: "quokka OR wombat",
+			want:  `quokka OR wombat`,
+		},
+		{
+			name:  "and quotes operators",
+			query: "quokka OR wombat",
+			opts:  searchOptions{Operator: "AND"},
+			want:  `"quokka" AND "OR" AND "wombat"`,
+		},
+		{
+			name:  "operators are quoted",
+			query: `C++ "quoted" NOT`,
+			opts:  searchOptions{Operator: "AND"},
+			want:  `"C++" AND """quoted""" AND "NOT"`,
+		},
+		{
+			name:  "exact phrase",
+			query: "  neural \t network ",
+			opts:  searchOptions{Exact: true, Operator: "AND"},
+			want:  `"neural network"`,
+		},
+		{
+			name:  "exact phrase with quotes",
+			query: `say "hi" NOT`,
+			opts:  searchOptions{Exact: true},
+			want:  `"say ""hi"" NOT"`,
+		},
+		{
+			name:  "fields",
+			query: "network",
+			opts:  searchOptions{Fields: []string{"filename:report", "content:deep learning"}},
+			want:  `(network) AND filename : "report" AND content_idx : "deep learning"`,
+		},
+		{
+			name:  "document scope",
+			query: "neural network",
+			opts:  searchOptions{Scope: scopeDocument},
+			want:  `"neural" OR "network"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildMatchQuery(tt.query, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildMatchQueryInvalidField(t *testing.T) {
+	for _, field := range []string{"title:report", "filename", "content:"} {
+		if _, err := buildMatchQuery("network", searchOptions{Fields: []string{field}}); err == nil {
+			t.Errorf("--field %q: expected an error", field)
+		}
+	}
+}
+
+func TestParsePageRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		from, to int
+		wantErr  bool
+	}{
+		{value: "5", from: 5, to: 5},
+		{value: "1-10", from: 1, to: 10},
+		{value: " 3 - 4 ", from: 3, to: 4},
+		{value: "20-", from: 20, to: 0},
+		{value: "0", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "10-2", wantErr: true},
+		{value: "a-b", wantErr: true},
+		{value: "3-0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			from, to, err := parsePageRange(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d-%d", from, to)
+				}
+				return
+			}
+			if err != nil || from != tt.from || to != tt.to {
+				t.Errorf("got %d-%d (%v), want %d-%d", from, to, err, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestQuoteDottedTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"v1.2", `"v1.2"`},
+		{"2023-01-15*", `"2023-01-15"*`},
+		{"amd64/linux", `"amd64/linux"`},
+		{"release v1.2 notes", `release "v1.2" notes`},
+		{`"v1.2 release" v1.3`, `"v1.2 release" "v1.3"`},
+		{"(v1.2 OR v1.3) AND go", `("v1.2" OR "v1.3") AND go`},
+		{"plain words", "plain words"},
+		{"trailing. dot", "trailing. dot"},
+		{"a*b", "a*b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := quoteDottedTerms(tt.query); got != tt.want {
+				t.Errorf("quoteDottedTerms(%q) = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageRuns(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages []int
+		want  [][]int
+	}{
+		{"empty", nil, nil},
+		{"single page", []int{4}, [][]int{{4}}},
+		{"consecutive pages", []int{3, 1, 2}, [][]int{{1, 2, 3}}},
+		{"gaps split runs", []int{9, 1, 2, 5, 7, 8}, [][]int{{1, 2}, {5}, {7, 8, 9}}},
+		{"segments of a page stay together", []int{2, 4, 2, 3}, [][]int{{2, 2, 3, 4}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []database.SearchResult
+			for _, page := range tt.pages {
+				results = append(results, database.SearchResult{PageNum: page})
+			}
+			var got [][]int
+			for _, run := range pageRuns(results) {
+				var pages []int
+				for _, result := range run {
+					pages = append(pages, result.PageNum)
+				}
+				got = append(got, pages)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "24h", want: 24 * time.Hour},
+		{value: "90m", want: 90 * time.Minute},
+		{value: "7d", want: 7 * 24 * time.Hour},
+		{value: "2w", want: 14 * 24 * time.Hour},
+		{value: "1.5d", want: 36 * time.Hour},
+		{value: "0d", wantErr: true},
+		{value: "-1h", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSince(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %v (%v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/pdf"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+// selftestPhrase is the text of the generated PDF, searched for by selftest
+const selftestPhrase = "pdf-fts selftest marker quokka"
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check that extraction, indexing and search work in this build",
+	Long: util.Dedent(`
+		Generate a one-page PDF, extract its text, index it in a temporary
+		database and search it, reporting each step. Use it to diagnose a broken
+		build or environment (missing FTS5 support, MuPDF linking problems)
+		without touching your files or your index.
+	`),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSelftest()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(selftestCmd)
+}
+
+func runSelftest() error {
+	dir, err := os.MkdirTemp("", "pdf-fts-selftest-")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pdfPath := filepath.Join(dir, "selftest.pdf")
+	var pages []pdf.Page
+	var testDB *database.DB
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"Load MuPDF", func() error {
+			return pdf.CheckMuPDF()
+		}},
+		{"Generate PDF", func() error {
+			return os.WriteFile(pdfPath, pdf.SamplePDF(selftestPhrase), 0o644)
+		}},
+		{"Extract text (MuPDF)", func() error {
+			pages, err = pdf.New(cfg.Verbose).ExtractPages(pdfPath)
+			if err != nil {
+				return err
+			}
+			if len(pages) != 1 || !strings.Contains(pages[0].Content, selftestPhrase) {
+				return fmt.Errorf("expected one page containing %q, got %d page(s)", selftestPhrase, len(pages))
+			}
+			return nil
+		}},
+		{"Create database (SQLite FTS5)", func() error {
+			testDB, err = database.New(database.MemoryPath, databaseOptions())
+			return err
+		}},
+		{"Index pages", func() error {
+			return testDB.UpsertPDFData(pdfPath, "selftest", toDBPages(pages), 0)
+		}},
+		{"Search", func() error {
+			results, err := testDB.Search("quokka", database.SearchOptions{Limit: 5})
+			if err != nil {
+				return err
+			}
+			if len(results) != 1 || results[0].Path != pdfPath || results[0].PageNum != 1 {
+				return fmt.Errorf("expected a single hit on page 1, got %d result(s)", len(results))
+			}
+			return nil
+		}},
+	}
+	defer func() {
+		if testDB != nil {
+			testDB.Close()
+		}
+	}()
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			fmt.Printf("FAIL  %s: %v\n", step.name, err)
+			return fmt.Errorf("selftest failed")
+		}
+		fmt.Printf("ok    %s\n", step.name)
+	}
+
+	fmt.Println("Selftest passed.")
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/pdf"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var verifyHashesCmd = &cobra.Command{
+	Use:   "verify-hashes",
+	Short: "Check that the indexed files still match their stored hash",
+	Long: util.Dedent(`
+		Hash every indexed file again and report the files whose content changed
+		since they were scanned (modified or corrupted outside a scan) and the
+		files that no longer exist. Nothing is removed from the index. Use
+		--rescan-changed to index the changed files again right away.
+	`),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		workers, _ := cmd.Flags().GetInt("workers-io")
+		rescan, _ := cmd.Flags().GetBool("rescan-changed")
+
+		documents, err := db.ListDocuments(database.ListByPath, 0)
+		if err != nil {
+			return err
+		}
+		if len(documents) == 0 {
+			fmt.Println("No documents indexed. Run 'scan' to index some files.")
+			return nil
+		}
+
+		changed, missing, ok := verifyHashes(documents, workers)
+
+		if len(changed) > 0 {
+			fmt.Printf("Changed (%d):\n", len(changed))
+			for _, path := range changed {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+		if len(missing) > 0 {
+			fmt.Printf("Missing (%d):\n", len(missing))
+			for _, path := range missing {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+		fmt.Printf("%d file(s) ok, %d changed, %d missing.\n", ok, len(changed), len(missing))
+
+		if rescan && len(changed) > 0 {
+			fmt.Printf("\nRescanning %d changed file(s)...\n", len(changed))
+			if _, err := scanFolders(changed, defaultScanOptions()); err != nil {
+				return err
+			}
+			changed = nil
+		}
+
+		if len(changed) > 0 || len(missing) > 0 {
+			return fmt.Errorf("%d changed and %d missing file(s)", len(changed), len(missing))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyHashesCmd)
+	addDatabaseFlag(verifyHashesCmd)
+	verifyHashesCmd.Flags().Int("workers-io", defaultWorkersIO, "number of files hashed in parallel")
+	verifyHashesCmd.Flags().Bool("rescan-changed", false, "index the changed files again after the check")
+}
+
+// verifyHashes hashes the indexed documents in parallel, returning the paths
+// whose hash differs from the stored one, the paths that don't exist anymore
+// and the number of files that match. Files that can't be read for other
+// reasons are reported as changed.
+func verifyHashes(documents []database.DocumentSummary, workers int) (changed, missing []string, ok int) {
+	pdfProcessor := pdf.New(cfg.Verbose)
+	progress := newProgress("verifying", "Verifying hashes", len(documents))
+
+	hashes := make([]string, len(documents))
+	errs := make([]error, len(documents))
+	parallelEach(workers, len(documents), func(i int) {
+		hashes[i], errs[i] = pdfProcessor.HashFile(documents[i].Path)
+	}, func(i int) {
+		progress.Step(documents[i].Path)
+	})
+	progress.Finish()
+
+	for i, doc := range documents {
+		switch {
+		case errors.Is(errs[i], fs.ErrNotExist):
+			missing = append(missing, doc.Path)
+		case errs[i] != nil:
+			fmt.Fprintf(os.Stderr, "Warning: Failed to hash %s: %v\n", doc.Path, errs[i])
+			changed = append(changed, doc.Path)
+		case hashes[i] != doc.Hash:
+			changed = append(changed, doc.Path)
+		default:
+			ok++
+		}
+	}
+	return changed, missing, ok
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var volumesCmd = &cobra.Command{
+	Use:   "volumes [files...]",
+	Short: "List or group files that are volumes of the same document",
+	Long: util.Dedent(`
+		Files in the same directory whose names only differ by a volume number
+		(like "book vol1.pdf" and "book vol2.pdf") are grouped by 'scan
+		--group-volumes', and search results show their page in the whole document.
+		Without flags this lists the groups. Use --set to group the given files, in
+		order, under a document name, overriding the automatic grouping, and --unset
+		to ungroup a document.
+	`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, _ := cmd.Flags().GetString("set")
+		unset, _ := cmd.Flags().GetString("unset")
+
+		switch {
+		case set != "":
+			if len(args) == 0 {
+				return fmt.Errorf("--set requires the files of the document, in order")
+			}
+			paths := make([]string, len(args))
+			for i, arg := range args {
+				paths[i] = filepath.Clean(arg)
+			}
+			if err := db.SetVolumes(set, paths); err != nil {
+				return err
+			}
+			fmt.Printf("Grouped %d file(s) as %q.\n", len(paths), set)
+			return nil
+
+		case unset != "":
+			removed, err := db.RemoveVolumes(unset)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Ungrouped %d file(s) of %q.\n", removed, unset)
+			return nil
+		}
+
+		return listVolumes()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(volumesCmd)
+	volumesCmd.Flags().String("set", "", "group the given files, in order, as volumes of this document")
+	volumesCmd.Flags().String("unset", "", "ungroup the files of this document")
+	volumesCmd.MarkFlagsMutuallyExclusive("set", "unset")
+}
+
+// listVolumes prints the grouped documents with the page range of each volume
+func listVolumes() error {
+	volumes, err := db.Volumes()
+	if err != nil {
+		return err
+	}
+	if len(volumes) == 0 {
+		fmt.Println("No documents split across volumes.")
+		return nil
+	}
+
+	paths := make([]string, 0, len(volumes))
+	for path := range volumes {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		a, b := volumes[paths[i]], volumes[paths[j]]
+		if a.Document != b.Document {
+			return a.Document < b.Document
+		}
+		return a.Number < b.Number
+	})
+
+	lastDocument := ""
+	for _, path := range paths {
+		volume := volumes[path]
+		if volume.Document != lastDocument {
+			fmt.Println(volume.Document)
+			lastDocument = volume.Document
+		}
+		fmt.Printf("  Volume %d (from document page %d): %s\n", volume.Number, volume.DocumentPage(1), path)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Default pool sizes: extraction is CPU-bound so one worker per core is
+// enough, while hashing mostly waits on reads and benefits from more requests
+// in flight, especially on SSDs
+var (
+	defaultWorkersCPU = runtime.NumCPU()
+	defaultWorkersIO  = max(4, 2*runtime.NumCPU())
+)
+
+// parallelEach calls fn for every index in [0, count) on a pool of workers,
+// and onDone on the calling goroutine each time a call completes, so onDone
+// can safely update progress and write to the database
+func parallelEach(workers, count int, fn func(i int), onDone func(i int)) {
+	workers = max(1, min(workers, count))
+
+	indexes := make(chan int)
+	done := make(chan int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+				done <- i
+			}
+		}()
+	}
+
+	go func() {
+		for i := range count {
+			indexes <- i
+		}
+		close(indexes)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for i := range done {
+		onDone(i)
+	}
+}
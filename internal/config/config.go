@@ -7,18 +7,38 @@ import (
 	"path/filepath"
 )
 
+// DefaultBoundaryMarkers are the files or directories marking a project root
+var DefaultBoundaryMarkers = []string{".git"}
+
 // Config holds global application configuration
 type Config struct {
 	DBPath  string
 	Verbose bool
+
+	// BoundaryMarkers stops the database discovery at the first directory
+	// containing any of these entries, treating it as the project root
+	BoundaryMarkers []string
 }
 
 // New creates a new configuration with defaults
 func New() *Config {
-	return &Config{}
+	return &Config{
+		BoundaryMarkers: DefaultBoundaryMarkers,
+	}
+}
+
+// isBoundary reports whether dir contains one of the boundary markers
+func (c *Config) isBoundary(dir string) bool {
+	for _, marker := range c.BoundaryMarkers {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
 }
 
-// FindExistingDBPath searches for an existing database file up the directory tree
+// FindExistingDBPath searches for an existing database file up the directory tree,
+// stopping at the project boundary or at the filesystem root
 func (c *Config) FindExistingDBPath() error {
 	dbName := "fts.db"
 
@@ -28,7 +48,7 @@ func (c *Config) FindExistingDBPath() error {
 		return fmt.Errorf("failed to get current directory: %w", err)
 	}
 
-	// Search up the directory tree until we reach the root
+	// Search up the directory tree until we reach a boundary or the root
 	for {
 		dbPath := filepath.Join(currentDir, dbName)
 		log.Printf("Searching for database at: %s\n", dbPath) // Add logging here
@@ -39,6 +59,11 @@ func (c *Config) FindExistingDBPath() error {
 			return nil
 		}
 
+		if c.isBoundary(currentDir) {
+			log.Printf("Reached project boundary at: %s\n", currentDir)
+			break
+		}
+
 		// Move to parent directory
 		parentDir := filepath.Dir(currentDir)
 
@@ -1,14 +1,36 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/aziis98/pdf-fts/internal/util"
+	"github.com/mattn/go-sqlite3"
 )
 
+// SchemaVersion is the version of the text normalization written by this
+// build. Bump it when a change requires re-indexing existing files.
+const SchemaVersion = 3
+
+// LayoutVersion is the version of the tables and columns created by
+// initSchema. Bump it when a migration adds something queries rely on, so
+// read-only opens refuse databases that haven't been migrated yet.
+const LayoutVersion = 1
+
+// legacySchemaVersion is assumed for databases created before versions were recorded
+const legacySchemaVersion = 1
+
 // executor defines an interface for executing SQL queries, compatible with *sql.DB and *sql.Tx.
 type executor interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
@@ -20,16 +42,121 @@ type DB struct {
 	verbose bool
 }
 
-// New creates a new database connection and initializes the schema
-func New(dbPath string, verbose bool) (*DB, error) {
-	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=ON")
+// Options tunes the connection pool and the SQLite pragmas of a database
+type Options struct {
+	Verbose bool
+
+	// MaxOpenConns and MaxIdleConns size the sql.DB pool, zero open
+	// connections means no limit. Callers running queries while reading the
+	// rows of another need at least 2.
+	MaxOpenConns int
+	MaxIdleConns int
+
+	// BusyTimeout is how long a statement waits for a lock held by another connection
+	BusyTimeout time.Duration
+	// CacheSize is the page cache size, in pages or in KiB when negative like
+	// PRAGMA cache_size, zero keeps the SQLite default
+	CacheSize int
+	// MmapSize is the number of bytes of the file accessed through memory
+	// mapping, zero keeps the SQLite default
+	MmapSize int64
+	// Synchronous is OFF, NORMAL, FULL or EXTRA, empty keeps the SQLite default
+	Synchronous string
+}
+
+// SynchronousModes are the values accepted for Options.Synchronous
+var SynchronousModes = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		MaxIdleConns: 2, // the database/sql default
+		BusyTimeout:  5 * time.Second,
+	}
+}
+
+// open opens a connection pool on dsn with the pragmas and pool size of opts.
+// Pragmas without a DSN parameter are set on each new connection.
+func open(dsn string, opts Options) (*sql.DB, error) {
+	params := []string{"_busy_timeout=" + strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10)}
+	if opts.CacheSize != 0 {
+		params = append(params, "_cache_size="+strconv.Itoa(opts.CacheSize))
+	}
+	if opts.Synchronous != "" {
+		if !slices.Contains(SynchronousModes, strings.ToUpper(opts.Synchronous)) {
+			return nil, fmt.Errorf("invalid synchronous mode %q, expected one of: %s", opts.Synchronous, strings.Join(SynchronousModes, ", "))
+		}
+		params = append(params, "_synchronous="+strings.ToUpper(opts.Synchronous))
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	dsn += separator + strings.Join(params, "&")
+
+	sqliteDriver := &sqlite3.SQLiteDriver{}
+	if opts.MmapSize != 0 {
+		sqliteDriver.ConnectHook = func(conn *sqlite3.SQLiteConn) error {
+			_, err := conn.Exec("PRAGMA mmap_size = "+strconv.FormatInt(opts.MmapSize, 10), nil)
+			return err
+		}
+	}
+
+	db := sql.OpenDB(connector{driver: sqliteDriver, dsn: dsn})
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	return db, nil
+}
+
+// connector opens connections on a DSN with a configured driver, which
+// sql.Open can't do without registering the driver under a new name
+type connector struct {
+	driver *sqlite3.SQLiteDriver
+	dsn    string
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// MemoryPath given to New opens an empty in-memory database, discarded when closed
+const MemoryPath = ":memory:"
+
+// memoryDatabases numbers the databases opened at MemoryPath, each is distinct
+var memoryDatabases atomic.Int64
+
+// IsMemory reports whether dbPath is MemoryPath or an in-memory SQLite URI
+// like "file:name?mode=memory&cache=shared", which has no file on disk
+func IsMemory(dbPath string) bool {
+	return dbPath == MemoryPath || strings.Contains(dbPath, "mode=memory")
+}
+
+// New creates a new database connection and initializes the schema. dbPath
+// may be an in-memory database, see IsMemory.
+func New(dbPath string, opts Options) (*DB, error) {
+	dsn := dbPath + "?_journal_mode=WAL&_foreign_keys=ON"
+	if IsMemory(dbPath) {
+		// Every connection of the pool must see the same database, which
+		// lives as long as one of them is open
+		if dbPath == MemoryPath {
+			dbPath = fmt.Sprintf("file:pdf-fts-%d?mode=memory&cache=shared", memoryDatabases.Add(1))
+		}
+		dsn = dbPath + "&_foreign_keys=ON"
+		opts.MaxIdleConns = max(opts.MaxIdleConns, 1)
+	}
+
+	db, err := open(dsn, opts)
 	if err != nil {
 		return nil, fmt.Errorf("opening database at %s: %w", dbPath, err)
 	}
 
 	dbWrapper := &DB{
 		DB:      db,
-		verbose: verbose,
+		verbose: opts.Verbose,
 	}
 
 	if err := dbWrapper.initSchema(); err != nil {
@@ -40,6 +167,97 @@ func New(dbPath string, verbose bool) (*DB, error) {
 	return dbWrapper, nil
 }
 
+// NewReadOnly opens an existing database without modifying it, skipping the
+// schema creation and migrations. It fails if the file isn't a pdf-fts
+// database or has an older layout, see Validate.
+func NewReadOnly(dbPath string, opts Options) (*DB, error) {
+	if err := Validate(dbPath); err != nil {
+		return nil, err
+	}
+
+	db, err := open("file:"+dbPath+"?mode=ro", opts)
+	if err != nil {
+		return nil, fmt.Errorf("opening database at %s: %w", dbPath, err)
+	}
+
+	return &DB{
+		DB:      db,
+		verbose: opts.Verbose,
+	}, nil
+}
+
+// IsBusy reports whether an error was caused by another connection holding a
+// lock on the database, e.g. a running scan
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+// Validate checks that the file at dbPath is a pdf-fts database whose tables
+// have been migrated to LayoutVersion, so it can be opened read-only, without
+// modifying it
+func Validate(dbPath string) error {
+	return validate(dbPath, true)
+}
+
+// ValidateMigratable checks that the file at dbPath is a pdf-fts database of
+// any layout version, which New migrates, without modifying it
+func ValidateMigratable(dbPath string) error {
+	return validate(dbPath, false)
+}
+
+// validate looks for the pdfs and pdfs_fts tables and, with checkLayout, for
+// a layout version not older than LayoutVersion
+func validate(dbPath string, checkLayout bool) error {
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return fmt.Errorf("opening database at %s: %w", dbPath, err)
+	}
+	defer db.Close()
+
+	var tables, metaTables int
+	err = db.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE name IN ('pdfs', 'pdfs_fts')),
+			COUNT(*) FILTER (WHERE name = 'meta')
+		FROM sqlite_master WHERE type = 'table'
+	`).Scan(&tables, &metaTables)
+	if err != nil {
+		return fmt.Errorf("%s is not a pdf-fts database: %w", dbPath, err)
+	}
+	if tables != 2 {
+		return fmt.Errorf("%s is not a pdf-fts database", dbPath)
+	}
+	if !checkLayout {
+		return nil
+	}
+
+	// Opening read-only skips the migrations, an older database may lack the
+	// columns and tables queries expect. Databases migrated before the layout
+	// was recorded have no version.
+	version := 0
+	if metaTables > 0 {
+		var value string
+		err := db.QueryRow("SELECT value FROM meta WHERE key = 'layout_version'").Scan(&value)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("reading layout version of %s: %w", dbPath, err)
+		}
+		if err == nil {
+			if version, err = strconv.Atoi(value); err != nil {
+				return fmt.Errorf("invalid layout version %q in %s: %w", value, dbPath, err)
+			}
+		}
+	}
+	if version < LayoutVersion {
+		return fmt.Errorf("%s was created by an older version of pdf-fts (layout %d, current %d), run 'pdf-fts scan' on it to migrate it",
+			dbPath, version, LayoutVersion)
+	}
+	return nil
+}
+
 // initSchema creates the necessary tables and triggers
 func (db *DB) initSchema() error {
 	// Create main table for per-page storage
@@ -48,7 +266,11 @@ func (db *DB) initSchema() error {
 			path TEXT NOT NULL,
 			page_num INTEGER NOT NULL,
 			hash TEXT NOT NULL,
+			filename TEXT,
+			path_key TEXT,
 			content TEXT,
+			original TEXT,
+			real_page INTEGER,
 			last_scanned TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			PRIMARY KEY (path, page_num)
 		);
@@ -60,6 +282,66 @@ func (db *DB) initSchema() error {
 		return fmt.Errorf("creating pdfs table: %w", err)
 	}
 
+	// Add columns introduced after the initial schema to existing databases
+	if err := db.ensureColumn("pdfs", "original", "TEXT"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn("pdfs", "filename", "TEXT"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn("pdfs", "path_key", "TEXT"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn("pdfs", "quality", "REAL"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn("pdfs", "real_page", "INTEGER"); err != nil {
+		return err
+	}
+	if err := db.backfillPathColumns(); err != nil {
+		return err
+	}
+
+	if err := db.initMeta(); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS extraction_cache (
+			hash TEXT NOT NULL,
+			page_num INTEGER NOT NULL,
+			content TEXT,
+			original TEXT,
+			PRIMARY KEY (hash, page_num)
+		);
+	`); err != nil {
+		return fmt.Errorf("creating extraction_cache table: %w", err)
+	}
+
+	if err := db.createVolumesTable(); err != nil {
+		return err
+	}
+
+	if err := db.createReadStatusTable(); err != nil {
+		return err
+	}
+
+	if err := db.createRawPagesTable(); err != nil {
+		return err
+	}
+
+	if err := db.createScanHistoryTable(); err != nil {
+		return err
+	}
+
+	if err := db.createPageOffsetsTable(); err != nil {
+		return err
+	}
+
+	if err := db.createSectionsTable(); err != nil {
+		return err
+	}
+
 	// Create FTS table using helper
 	if err := db.createFTSTable(db.DB); err != nil {
 		return err
@@ -70,6 +352,190 @@ func (db *DB) initSchema() error {
 		return err
 	}
 
+	// FTS tables created by older versions lack the filename column and can't
+	// be altered, so they are rebuilt with the current layout
+	hasFilename, err := db.hasColumn("pdfs_fts", "filename")
+	if err != nil {
+		return err
+	}
+	if !hasFilename {
+		if _, err := db.RebuildFTS(nil); err != nil {
+			return fmt.Errorf("upgrading FTS index: %w", err)
+		}
+	}
+
+	// The tables now match this build, whatever the version of the data
+	if err := db.SetMeta("layout_version", strconv.Itoa(LayoutVersion)); err != nil {
+		return fmt.Errorf("recording layout version: %w", err)
+	}
+
+	return nil
+}
+
+// backfillPathColumns stores the columns derived from the path (the base
+// filename and the folded path) of rows indexed before they were introduced
+func (db *DB) backfillPathColumns() error {
+	rows, err := db.Query("SELECT DISTINCT path FROM pdfs WHERE filename IS NULL OR path_key IS NULL")
+	if err != nil {
+		return fmt.Errorf("querying paths without derived columns: %w", err)
+	}
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return fmt.Errorf("scanning path without derived columns: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("querying paths without derived columns: %w", err)
+	}
+
+	for _, path := range paths {
+		_, err := db.Exec(
+			"UPDATE pdfs SET filename = ?, path_key = ? WHERE path = ?",
+			filepath.Base(path), util.FoldPath(path), path,
+		)
+		if err != nil {
+			return fmt.Errorf("storing derived columns of %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// initMeta creates the key/value meta table and records the schema version
+// for newly created databases. The layout version is recorded by initSchema
+// once the migrations succeed.
+func (db *DB) initMeta() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS meta (
+			key TEXT PRIMARY KEY,
+			value TEXT
+		);
+	`); err != nil {
+		return fmt.Errorf("creating meta table: %w", err)
+	}
+
+	// Only an empty database is known to match the current schema, older ones
+	// without a recorded version are treated as legacy
+	var isEmpty bool
+	if err := db.QueryRow("SELECT NOT EXISTS (SELECT 1 FROM pdfs)").Scan(&isEmpty); err != nil {
+		return fmt.Errorf("checking if database is empty: %w", err)
+	}
+	if isEmpty {
+		if _, err := db.Exec(
+			"INSERT OR IGNORE INTO meta (key, value) VALUES ('schema_version', ?)",
+			strconv.Itoa(SchemaVersion),
+		); err != nil {
+			return fmt.Errorf("recording schema version: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetMeta returns the value stored in the meta table for key, or an empty string if unset
+func (db *DB) GetMeta(key string) (string, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM meta WHERE key = ?", key).Scan(&value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("reading meta %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetMeta stores a value in the meta table
+func (db *DB) SetMeta(key, value string) error {
+	_, err := db.Exec(`
+		INSERT INTO meta (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
+	`, key, value)
+	if err != nil {
+		return fmt.Errorf("writing meta %s: %w", key, err)
+	}
+	return nil
+}
+
+// StoredSchemaVersion returns the schema version the indexed data was written with
+func (db *DB) StoredSchemaVersion() (int, error) {
+	value, err := db.GetMeta("schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if value == "" {
+		return legacySchemaVersion, nil
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", value, err)
+	}
+	return version, nil
+}
+
+// RecordScan stores the version of the tool that last scanned the database,
+// and marks the data as matching the current schema if fullRescan reports
+// that the indexed files were extracted again
+func (db *DB) RecordScan(toolVersion string, fullRescan bool) error {
+	if err := db.SetMeta("tool_version", toolVersion); err != nil {
+		return err
+	}
+	if fullRescan {
+		return db.SetMeta("schema_version", strconv.Itoa(SchemaVersion))
+	}
+	return nil
+}
+
+// hasColumn reports whether a table has the given column
+func (db *DB) hasColumn(table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return false, fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return false, fmt.Errorf("scanning columns of %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+
+	return false, nil
+}
+
+// ensureColumn adds a column to a table if it does not already exist
+func (db *DB) ensureColumn(table, column, definition string) error {
+	exists, err := db.hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	if db.verbose {
+		log.Printf("Adding column %s to table %s...", column, table)
+	}
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition)); err != nil {
+		return fmt.Errorf("adding column %s to %s: %w", column, table, err)
+	}
 	return nil
 }
 
@@ -82,6 +548,7 @@ func (db *DB) createFTSTable(exec executor) error {
 		CREATE VIRTUAL TABLE IF NOT EXISTS pdfs_fts USING fts5(
 			path UNINDEXED,
 			page_num UNINDEXED,
+			filename,
 			content_idx,
 			tokenize = 'trigram'
 		);
@@ -102,7 +569,7 @@ func (db *DB) createTriggers(exec executor) error {
 			CREATE TRIGGER IF NOT EXISTS pdfs_after_insert
 			AFTER INSERT ON pdfs 
 			BEGIN
-				INSERT INTO pdfs_fts (path, page_num, content_idx) VALUES (new.path, new.page_num, new.content);
+				INSERT INTO pdfs_fts (path, page_num, filename, content_idx) VALUES (new.path, new.page_num, new.filename, new.content);
 			END;
 		`,
 		`
@@ -114,10 +581,10 @@ func (db *DB) createTriggers(exec executor) error {
 		`,
 		`
 			CREATE TRIGGER IF NOT EXISTS pdfs_after_update_content
-			AFTER UPDATE OF content ON pdfs
-			WHEN new.content IS NOT old.content
+			AFTER UPDATE OF content, filename ON pdfs
+			WHEN new.content IS NOT old.content OR new.filename IS NOT old.filename
 			BEGIN
-				UPDATE pdfs_fts SET content_idx = new.content WHERE path = new.path AND page_num = new.page_num;
+				UPDATE pdfs_fts SET filename = new.filename, content_idx = new.content WHERE path = new.path AND page_num = new.page_num;
 			END;
 		`,
 	}
@@ -138,21 +605,50 @@ func (db *DB) createTriggers(exec executor) error {
 
 // GetStoredHash retrieves the stored hash for a PDF file (from any page)
 func (db *DB) GetStoredHash(filePath string) (string, error) {
+	// An unknown file has no rows, and a document whose batched upsert didn't
+	// complete has some pending pages, both give an empty hash
 	var storedHash string
-	err := db.QueryRow("SELECT hash FROM pdfs WHERE path = ? LIMIT 1", filePath).Scan(&storedHash)
+	err := db.QueryRow("SELECT COALESCE(MIN(hash), '') FROM pdfs WHERE path = ?", filePath).Scan(&storedHash)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", nil // No hash stored, treat as no error but empty string
-		}
 		return "", fmt.Errorf("querying stored hash for %s: %w", filePath, err)
 	}
 	return storedHash, nil
 }
 
-// UpsertPDFData inserts or updates PDF data in the database for all pages
-func (db *DB) UpsertPDFData(filePath, hash string, pageContents []string) error {
+// Page holds the text stored for a single PDF page
+type Page struct {
+	Content  string // cleaned text with collapsed whitespace, indexed by FTS
+	Original string // cleaned text with line breaks preserved, used for display
+	// PageNum is the page of the document this row belongs to when long pages
+	// are split into several rows (segments), zero if the row is the page itself
+	PageNum int
+	// Quality estimates how readable the extracted text is, from 0 to 1
+	Quality float64
+}
+
+// UpsertPDFData inserts or updates PDF data in the database for all pages.
+// With a batchSize greater than zero, documents with more pages are written
+// in transactions of at most batchSize pages to bound the size of each
+// transaction and of the WAL. Pages written by a batch are marked with an
+// empty hash until the last transaction stores the real one, so a failure
+// midway leaves the document looking changed and the next scan redoes it.
+func (db *DB) UpsertPDFData(filePath, hash string, pages []Page, batchSize int) error {
 	if db.verbose {
-		log.Printf("Upserting PDF data for: %s (%d pages)", filePath, len(pageContents))
+		log.Printf("Upserting PDF data for: %s (%d pages)", filePath, len(pages))
+	}
+
+	if batchSize <= 0 || len(pages) <= batchSize {
+		return db.upsertBatch(filePath, hash, pages, 0, true)
+	}
+
+	for start := 0; start < len(pages); start += batchSize {
+		end := min(start+batchSize, len(pages))
+		if db.verbose {
+			log.Printf("Writing pages %d-%d of %s", start+1, end, filePath)
+		}
+		if err := db.upsertBatch(filePath, pendingHash, pages[start:end], start, false); err != nil {
+			return err
+		}
 	}
 
 	tx, err := db.Begin()
@@ -161,107 +657,1176 @@ func (db *DB) UpsertPDFData(filePath, hash string, pageContents []string) error
 	}
 	defer tx.Rollback()
 
-	// First, delete all existing pages for this file
-	_, err = tx.Exec("DELETE FROM pdfs WHERE path = ?", filePath)
+	if _, err := tx.Exec("UPDATE pdfs SET hash = ? WHERE path = ?", hash, filePath); err != nil {
+		return fmt.Errorf("storing hash for %s: %w", filePath, err)
+	}
+	if err := deleteTrailingPages(tx, filePath, len(pages)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction for %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// pendingHash marks pages written by a batched upsert that didn't complete yet
+const pendingHash = ""
+
+// upsertBatch writes pages starting at the given offset in the document in a
+// single transaction, and if last is set deletes the pages past them
+func (db *DB) upsertBatch(filePath, hash string, pages []Page, offset int, last bool) error {
+	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("deleting existing pages for %s: %w", filePath, err)
+		return fmt.Errorf("beginning transaction for %s: %w", filePath, err)
 	}
+	defer tx.Rollback()
 
-	// Insert all pages
+	// Insert new pages and update existing ones in place, the update trigger
+	// only touches the FTS index for pages whose content actually changed
 	stmt, err := tx.Prepare(`
-		INSERT INTO pdfs (path, page_num, hash, content, last_scanned) 
-		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO pdfs (path, page_num, hash, filename, path_key, content, original, real_page, quality, last_scanned) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (path, page_num) DO UPDATE SET
+			hash = excluded.hash,
+			filename = excluded.filename,
+			path_key = excluded.path_key,
+			content = excluded.content,
+			original = excluded.original,
+			real_page = excluded.real_page,
+			quality = excluded.quality,
+			last_scanned = excluded.last_scanned
 	`)
 	if err != nil {
-		return fmt.Errorf("preparing insert statement for %s: %w", filePath, err)
+		return fmt.Errorf("preparing upsert statement for %s: %w", filePath, err)
 	}
 	defer stmt.Close()
 
-	for pageNum, content := range pageContents {
-		_, err = stmt.Exec(filePath, pageNum+1, hash, content) // page numbers are 1-indexed
+	filename := filepath.Base(filePath)
+	pathKey := util.FoldPath(filePath)
+	for i, page := range pages {
+		pageNum := offset + i + 1 // page numbers are 1-indexed
+
+		// Rows of whole pages leave real_page NULL, queries use COALESCE(real_page, page_num)
+		var realPage any
+		if page.PageNum != 0 && page.PageNum != pageNum {
+			realPage = page.PageNum
+		}
+		_, err = stmt.Exec(filePath, pageNum, hash, filename, pathKey, page.Content, page.Original, realPage, page.Quality)
 		if err != nil {
-			return fmt.Errorf("inserting page %d for %s: %w", pageNum+1, filePath, err)
+			return fmt.Errorf("upserting page %d for %s: %w", pageNum, filePath, err)
+		}
+	}
+
+	if last {
+		if err := deleteTrailingPages(tx, filePath, offset+len(pages)); err != nil {
+			return err
 		}
 	}
 
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("committing transaction for %s: %w", filePath, err)
 	}
-
 	return nil
 }
 
-// Define a struct to hold search results
-type SearchResult struct {
-	Path        string
-	PageNum     int
-	Snippet     string
-	LastScanned string
-}
-
-// Modify the Search method
-func (db *DB) Search(queryTerm string, limit int) ([]SearchResult, error) {
-	if queryTerm == "" {
-		return nil, nil
+// deleteTrailingPages deletes the pages past pageCount if the document got shorter
+func deleteTrailingPages(tx *sql.Tx, filePath string, pageCount int) error {
+	_, err := tx.Exec("DELETE FROM pdfs WHERE path = ? AND page_num > ?", filePath, pageCount)
+	if err != nil {
+		return fmt.Errorf("deleting trailing pages for %s: %w", filePath, err)
 	}
+	return nil
+}
 
+// GetCachedPages returns the pages previously extracted from a file with the
+// given hash from the extraction cache. It returns nil if the hash is unknown.
+func (db *DB) GetCachedPages(hash string) ([]Page, error) {
 	rows, err := db.Query(
-		`
-			SELECT
-				p.path,
-				p.page_num,
-				snippet(pdfs_fts, 2, '[HL]', '[/HL]', '...', 140) AS snippet,
-				p.last_scanned
-			FROM pdfs_fts
-			JOIN pdfs AS p ON pdfs_fts.path = p.path AND pdfs_fts.page_num = p.page_num
-			WHERE pdfs_fts MATCH ? ORDER BY rank LIMIT ?;
-		`,
-		queryTerm, limit,
+		"SELECT COALESCE(content, ''), COALESCE(original, '') FROM extraction_cache WHERE hash = ? ORDER BY page_num",
+		hash,
 	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("querying cached pages for %s: %w", hash, err)
 	}
 	defer rows.Close()
 
-	var results []SearchResult
+	var pages []Page
 	for rows.Next() {
-		var result SearchResult
-		if err := rows.Scan(&result.Path, &result.PageNum, &result.Snippet, &result.LastScanned); err != nil {
-			return nil, err
+		var page Page
+		if err := rows.Scan(&page.Content, &page.Original); err != nil {
+			return nil, fmt.Errorf("scanning cached page for %s: %w", hash, err)
 		}
-		results = append(results, result)
+		pages = append(pages, page)
 	}
-
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("querying cached pages for %s: %w", hash, err)
 	}
-
-	return results, nil
+	return pages, nil
 }
 
-// RebuildFTS drops and recreates the FTS index
-func (db *DB) RebuildFTS() error {
-	if db.verbose {
-		log.Println("Rebuilding Full-Text Search index...")
-	}
-
+// CachePages stores the pages extracted from a file in the extraction cache
+func (db *DB) CachePages(hash string, pages []Page) error {
 	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("beginning transaction: %w", err)
+		return fmt.Errorf("beginning transaction for cache of %s: %w", hash, err)
 	}
-	defer tx.Rollback() // Rollback if commit is not successful
+	defer tx.Rollback()
 
-	// Drop triggers
-	triggerNames := []string{"pdfs_after_insert", "pdfs_after_delete", "pdfs_after_update_content"}
-	for _, triggerName := range triggerNames {
-		if db.verbose {
-			log.Printf("Dropping trigger %s if exists...", triggerName)
+	if _, err := tx.Exec("DELETE FROM extraction_cache WHERE hash = ?", hash); err != nil {
+		return fmt.Errorf("deleting cached pages for %s: %w", hash, err)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO extraction_cache (hash, page_num, content, original) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("preparing cache insert statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for pageNum, page := range pages {
+		if _, err := stmt.Exec(hash, pageNum+1, page.Content, page.Original); err != nil {
+			return fmt.Errorf("caching page %d for %s: %w", pageNum+1, hash, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing cache of %s: %w", hash, err)
+	}
+	return nil
+}
+
+// ClearCache removes all entries from the extraction cache, returning the
+// number of cached documents removed
+func (db *DB) ClearCache() (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(DISTINCT hash) FROM extraction_cache").Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting cached documents: %w", err)
+	}
+	if _, err := db.Exec("DELETE FROM extraction_cache"); err != nil {
+		return 0, fmt.Errorf("clearing extraction cache: %w", err)
+	}
+	return count, nil
+}
+
+// schemaTables are all the tables created by initSchema, dropped by Reset
+var schemaTables = []string{"pdfs_fts", "pdfs", "extraction_cache", "volumes", "read_status", "raw_pages", "scan_history", "page_offsets", "sections", "meta"}
+
+// Reset drops every table and recreates an empty schema, returning the number
+// of documents removed
+func (db *DB) Reset() (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(DISTINCT path) FROM pdfs").Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting documents: %w", err)
+	}
+	// The generation outlives the meta table, so results cached before the
+	// reset can't match a generation reached again after it
+	generation, err := db.Generation()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction for reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := db.dropTriggers(tx); err != nil {
+		return 0, err
+	}
+	for _, table := range schemaTables {
+		if db.verbose {
+			log.Printf("Dropping table %s if exists...", table)
+		}
+		if _, err := tx.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			return 0, fmt.Errorf("dropping table %s: %w", table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing reset: %w", err)
+	}
+
+	if err := db.initSchema(); err != nil {
+		return 0, fmt.Errorf("recreating schema: %w", err)
+	}
+	if err := db.SetMeta(generationMeta, strconv.FormatInt(generation, 10)); err != nil {
+		return 0, err
+	}
+	if err := db.BumpGeneration(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Define a struct to hold search results
+type SearchResult struct {
+	Path        string
+	PageNum     int
+	Snippet     string
+	LastScanned string
+	Score       float64 // weighted bm25 rank, lower is more relevant
+	// Position is the fraction of the page text before the first occurrence of
+	// one of the PositionTerms, from 0 to 1, or -1 if unknown
+	Position float64
+	Length   int // characters of text in the page
+	// Database is the database the result comes from, set by callers
+	// merging the results of several databases
+	Database string
+	// PageOffset is the page offset of the file, see SetPageOffset
+	PageOffset int
+}
+
+// noMatchOffset is larger than any match offset, for terms not found in a page
+const noMatchOffset = "9223372036854775807"
+
+// DefaultSnippetEllipsis is the text marking where snippets were cut
+const DefaultSnippetEllipsis = "..."
+
+// SearchOptions controls which results Search returns
+type SearchOptions struct {
+	Limit  int
+	Offset int
+
+	// SnippetEllipsis marks where the snippet text was cut, it can be empty
+	SnippetEllipsis string
+
+	// Extension restricts results to files with this extension (e.g. ".pdf"), case-insensitively
+	Extension string
+
+	// InDir restricts results to files under this directory, PathGlob to paths
+	// matching this glob pattern. FoldPaths makes both case and accent insensitive.
+	InDir     string
+	PathGlob  string
+	FoldPaths bool
+
+	// ExcludePath drops the pages of this file from the results
+	ExcludePath string
+
+	// DistinctFiles returns only the best ranked page of each file, the limit
+	// and offset then count files
+	DistinctFiles bool
+
+	// DedupeByContent keeps a single copy of files with identical content
+	// (the same hash) stored under different paths: the most recently scanned,
+	// then the one with the shortest path
+	DedupeByContent bool
+
+	// LimitFiles makes the limit and offset count files instead of pages, all
+	// the matching pages of the selected files are returned
+	LimitFiles bool
+
+	// MinPageChars drops pages whose content is shorter than this many characters
+	MinPageChars int
+
+	// FromPage and ToPage restrict results to this range of pages, each bound
+	// is ignored when zero
+	FromPage int
+	ToPage   int
+
+	// OnlyRead and OnlyUnread restrict results to files marked or not marked as read
+	OnlyRead   bool
+	OnlyUnread bool
+
+	// ScannedSince restricts results to pages scanned at or after this time,
+	// ignored when zero
+	ScannedSince time.Time
+
+	// Section restricts results to pages within an outline section whose
+	// title contains it, case-insensitively. Documents without an outline
+	// have no sections and never match.
+	Section string
+
+	// MinQuality drops pages whose text quality is below this, pages scanned
+	// before quality was computed are kept
+	MinQuality float64
+
+	// PositionTerms are the words located in the page text to fill the result
+	// Position, which costs a scan of the text of each result
+	PositionTerms []string
+
+	// DocumentTerms restricts results to files where each of these MATCH
+	// expressions matches some page, not necessarily the same one
+	DocumentTerms []string
+
+	// SortByLength orders results from the longest page instead of by rank,
+	// with LimitFiles files are ordered by their longest matching page
+	SortByLength bool
+
+	// MaxRank drops results whose weighted bm25 score is above it, scores are
+	// negative and lower is more relevant. Zero or more disables the filter.
+	MaxRank float64
+
+	// FilenameWeight and ContentWeight scale the bm25 rank of matches in each
+	// column. When both are zero the weights set with SetRankWeights, or else
+	// the default ones, are used.
+	FilenameWeight float64
+	ContentWeight  float64
+}
+
+// Default bm25 column weights, favoring documents whose name matches the query
+const (
+	DefaultFilenameWeight = 10.0
+	DefaultContentWeight  = 1.0
+)
+
+// Search runs a full-text query and returns the matching pages ordered by rank
+func (db *DB) Search(queryTerm string, opts SearchOptions) ([]SearchResult, error) {
+	var results []SearchResult
+	err := db.SearchEach(queryTerm, opts, func(result SearchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SearchEach runs a full-text query and calls fn for each matching page in
+// rank order, reading the rows in batches instead of collecting them all. A
+// limit of zero or less returns all the matches. Iteration stops at the first
+// error from fn.
+func (db *DB) SearchEach(queryTerm string, opts SearchOptions, fn func(SearchResult) error) error {
+	if queryTerm == "" {
+		return nil
+	}
+
+	conditions := []string{"pdfs_fts MATCH ?"}
+	args := []any{queryTerm}
+
+	if opts.Extension != "" {
+		conditions = append(conditions, "lower(p.path) LIKE ? ESCAPE '\\'")
+		args = append(args, "%"+escapeLike(strings.ToLower(opts.Extension)))
+	}
+
+	pathColumn := "p.path"
+	fold := func(s string) string { return s }
+	if opts.FoldPaths {
+		pathColumn = "p.path_key"
+		fold = util.FoldPath
+	}
+
+	if opts.InDir != "" {
+		prefix := fold(strings.TrimSuffix(opts.InDir, "/") + "/")
+		conditions = append(conditions, "substr("+pathColumn+", 1, ?) = ?")
+		args = append(args, len([]rune(prefix)), prefix)
+	}
+
+	if opts.PathGlob != "" {
+		conditions = append(conditions, pathColumn+" GLOB ?")
+		args = append(args, fold(opts.PathGlob))
+	}
+
+	if opts.ExcludePath != "" {
+		conditions = append(conditions, "p.path != ?")
+		args = append(args, opts.ExcludePath)
+	}
+
+	if opts.MinPageChars > 0 {
+		conditions = append(conditions, "length(COALESCE(p.content, '')) >= ?")
+		args = append(args, opts.MinPageChars)
+	}
+
+	if opts.FromPage > 0 {
+		conditions = append(conditions, "COALESCE(p.real_page, p.page_num) >= ?")
+		args = append(args, opts.FromPage)
+	}
+	if opts.ToPage > 0 {
+		conditions = append(conditions, "COALESCE(p.real_page, p.page_num) <= ?")
+		args = append(args, opts.ToPage)
+	}
+
+	if opts.MinQuality > 0 {
+		conditions = append(conditions, "COALESCE(p.quality, 1) >= ?")
+		args = append(args, opts.MinQuality)
+	}
+
+	for _, term := range opts.DocumentTerms {
+		conditions = append(conditions, "p.path IN (SELECT path FROM pdfs_fts WHERE pdfs_fts MATCH ?)")
+		args = append(args, term)
+	}
+
+	if opts.DedupeByContent {
+		// Files still being written have an empty hash and are never copies
+		conditions = append(conditions, `(p.hash = '' OR p.path = (
+			SELECT path FROM pdfs AS copy WHERE copy.hash = p.hash
+			GROUP BY path
+			ORDER BY MAX(last_scanned) DESC, length(path), path
+			LIMIT 1
+		))`)
+	}
+
+	if opts.Section != "" {
+		// Nested sections overlap, a page matches through any enclosing one
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM sections AS s
+			WHERE s.path = p.path
+				AND COALESCE(p.real_page, p.page_num) BETWEEN s.from_page AND s.to_page
+				AND lower(s.title) LIKE ? ESCAPE '\'
+		)`)
+		args = append(args, "%"+escapeLike(strings.ToLower(opts.Section))+"%")
+	}
+
+	if !opts.ScannedSince.IsZero() {
+		// last_scanned is stored by CURRENT_TIMESTAMP, in UTC
+		conditions = append(conditions, "p.last_scanned >= ?")
+		args = append(args, opts.ScannedSince.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	if opts.OnlyRead {
+		conditions = append(conditions, "p.path IN (SELECT path FROM read_status)")
+	} else if opts.OnlyUnread {
+		conditions = append(conditions, "p.path NOT IN (SELECT path FROM read_status)")
+	}
+
+	filenameWeight, contentWeight, err := db.searchWeights(opts)
+	if err != nil {
+		return err
+	}
+	// The first occurrence of any position term, as a 1-based character offset,
+	// instr() returns 0 when a term is missing
+	matchOffset := "0"
+	var positionArgs []any
+	if len(opts.PositionTerms) > 0 {
+		var offsets []string
+		for _, term := range opts.PositionTerms {
+			offsets = append(offsets, "COALESCE(NULLIF(instr(lower(p.content), ?), 0), "+noMatchOffset+")")
+			positionArgs = append(positionArgs, strings.ToLower(term))
+		}
+		matchOffset = offsets[0]
+		if len(offsets) > 1 {
+			matchOffset = "min(" + strings.Join(offsets, ", ") + ")"
+		}
+	}
+
+	// The select list comes first in the query, the unindexed path and
+	// page_num columns get no weight
+	selectArgs := append([]any{opts.SnippetEllipsis, filenameWeight, contentWeight}, positionArgs...)
+	args = append(selectArgs, args...)
+
+	matches := `
+		SELECT
+			p.path AS path,
+			COALESCE(p.real_page, p.page_num) AS page,
+			snippet(pdfs_fts, 3, '[HL]', '[/HL]', ?, 140) AS snippet,
+			p.last_scanned AS last_scanned,
+			bm25(pdfs_fts, 0, 0, ?, ?) AS score,
+			` + matchOffset + ` AS match_offset,
+			length(COALESCE(p.content, '')) AS content_length
+		FROM pdfs_fts
+		JOIN pdfs AS p ON pdfs_fts.path = p.path AND pdfs_fts.page_num = p.page_num
+		WHERE ` + strings.Join(conditions, " AND ")
+
+	// bm25() can't be filtered in the WHERE clause of the MATCH query itself
+	if opts.MaxRank < 0 {
+		matches = `SELECT * FROM (` + matches + `) WHERE score <= ?`
+		args = append(args, opts.MaxRank)
+	}
+
+	// With DistinctFiles only the best ranked page of each file is kept
+	if opts.DistinctFiles {
+		matches = `
+			SELECT * FROM (
+				SELECT *, ROW_NUMBER() OVER (PARTITION BY path ORDER BY score) AS file_rank
+				FROM (` + matches + `)
+			)
+			WHERE file_rank = 1
+		`
+	}
+
+	pageOrder, fileOrder := "score", "MIN(score)"
+	if opts.SortByLength {
+		pageOrder, fileOrder = "content_length DESC, score", "-MAX(content_length)"
+	}
+
+	// Ties are broken by path and page so batches never overlap
+	fileWindow := opts.LimitFiles && !opts.DistinctFiles
+	order := "ORDER BY " + pageOrder + ", path, page LIMIT ? OFFSET ?"
+	if fileWindow {
+		// Files are ranked by their best page, the limit and offset select
+		// files and all their matching pages are returned, best file first
+		matches = `
+			SELECT * FROM (
+				SELECT *, DENSE_RANK() OVER (ORDER BY best_score, path) AS file_rank
+				FROM (
+					SELECT *, ` + fileOrder + ` OVER (PARTITION BY path) AS best_score
+					FROM (` + matches + `)
+				)
+			)
+			WHERE file_rank > ? AND file_rank <= ?
+		`
+		order = "ORDER BY file_rank, " + pageOrder + ", page"
+	}
+
+	query := `
+		SELECT
+			path, page, snippet, last_scanned, score,
+			CASE
+				WHEN match_offset BETWEEN 1 AND content_length
+				THEN (match_offset - 1) * 1.0 / content_length
+				ELSE -1
+			END,
+			content_length,
+			COALESCE((SELECT o.page_offset FROM page_offsets AS o WHERE o.path = m.path), 0)
+		FROM (` + matches + `) AS m
+		` + order + `;
+	`
+
+	// Results are read in batches of pages, or of files with fileWindow, and
+	// the rows are closed before calling fn, so it can run queries of its own
+	// even when the pool has a single connection
+	for start := opts.Offset; ; start += searchBatchSize {
+		size := searchBatchSize
+		if opts.Limit > 0 {
+			size = min(size, opts.Offset+opts.Limit-start)
+		}
+		if size <= 0 {
+			return nil
+		}
+
+		window := []any{size, start}
+		if fileWindow {
+			window = []any{start, start + size}
+		}
+		batch, err := db.searchBatch(query, slices.Concat(args, window))
+		if err != nil {
+			return err
+		}
+
+		read := len(batch)
+		if fileWindow {
+			read = 0
+			for i, result := range batch {
+				if i == 0 || result.Path != batch[i-1].Path {
+					read++
+				}
+			}
+		}
+
+		for _, result := range batch {
+			if err := fn(result); err != nil {
+				return err
+			}
+		}
+		if read < size {
+			return nil
+		}
+	}
+}
+
+// searchBatchSize is the number of results, or of files when the limit counts
+// files, SearchEach reads before calling back
+const searchBatchSize = 500
+
+// searchBatch runs a batch of the SearchEach query and reads all its rows
+func (db *DB) searchBatch(query string, args []any) ([]SearchResult, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		if err := rows.Scan(&result.Path, &result.PageNum, &result.Snippet, &result.LastScanned, &result.Score, &result.Position, &result.Length, &result.PageOffset); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// CountMatches returns the number of pages and of distinct documents matching a full-text query
+func (db *DB) CountMatches(queryTerm string) (pages int, docs int, err error) {
+	err = db.QueryRow(
+		`
+			SELECT COUNT(DISTINCT p.path || ':' || COALESCE(p.real_page, p.page_num)), COUNT(DISTINCT p.path)
+			FROM pdfs_fts
+			JOIN pdfs AS p ON pdfs_fts.path = p.path AND pdfs_fts.page_num = p.page_num
+			WHERE pdfs_fts MATCH ?
+		`,
+		queryTerm,
+	).Scan(&pages, &docs)
+	if err != nil {
+		return 0, 0, fmt.Errorf("counting matches: %w", err)
+	}
+	return pages, docs, nil
+}
+
+// TermMatch describes the occurrences of a matched term in a column of a page
+type TermMatch struct {
+	Column      string
+	Term        string
+	Count       int
+	FirstOffset int // rune offset of the first occurrence
+}
+
+// MatchExplanation describes why a page matched a query
+type MatchExplanation struct {
+	Score         float64 // weighted bm25 score like SearchResult.Score, lower is more relevant
+	ContentLength int     // length of the page content in runes
+	Matches       []TermMatch
+}
+
+// Highlight markers used to locate matches, they can't appear in extracted text
+const (
+	explainOpen  = "\x01"
+	explainClose = "\x02"
+)
+
+// searchWeights returns the bm25 weights of the file name and content
+// columns for a search, the stored defaults unless opts sets them
+func (db *DB) searchWeights(opts SearchOptions) (filenameWeight, contentWeight float64, err error) {
+	if opts.FilenameWeight != 0 || opts.ContentWeight != 0 {
+		return opts.FilenameWeight, opts.ContentWeight, nil
+	}
+	filenameWeight, contentWeight, _, err = db.RankWeights()
+	return filenameWeight, contentWeight, err
+}
+
+// ExplainMatch runs the query again restricted to a single page and reports
+// its bm25 score, weighted like Search with opts, and where each matched term
+// occurs
+func (db *DB) ExplainMatch(queryTerm, filePath string, pageNum int, opts SearchOptions) (*MatchExplanation, error) {
+	filenameWeight, contentWeight, err := db.searchWeights(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var filenameHL, contentHL string
+	explanation := &MatchExplanation{}
+
+	err = db.QueryRow(
+		`
+			SELECT
+				bm25(pdfs_fts, 0, 0, ?, ?) AS score,
+				highlight(pdfs_fts, 2, char(1), char(2)),
+				highlight(pdfs_fts, 3, char(1), char(2))
+			FROM pdfs_fts
+			WHERE pdfs_fts MATCH ? AND path = ? AND page_num IN (
+				SELECT page_num FROM pdfs WHERE path = ? AND COALESCE(real_page, page_num) = ?
+			)
+			ORDER BY score LIMIT 1;
+		`,
+		filenameWeight, contentWeight, queryTerm, filePath, filePath, pageNum,
+	).Scan(&explanation.Score, &filenameHL, &contentHL)
+	if err != nil {
+		return nil, fmt.Errorf("explaining match of %s page %d: %w", filePath, pageNum, err)
+	}
+
+	explanation.Matches = append(explanation.Matches, highlightedTerms("filename", filenameHL)...)
+	contentMatches := highlightedTerms("content", contentHL)
+	explanation.Matches = append(explanation.Matches, contentMatches...)
+
+	plain := strings.NewReplacer(explainOpen, "", explainClose, "").Replace(contentHL)
+	explanation.ContentLength = len([]rune(plain))
+
+	return explanation, nil
+}
+
+// highlightedTerms extracts the terms wrapped in explain markers from a
+// highlighted column, grouped case-insensitively in order of first occurrence
+func highlightedTerms(column, highlighted string) []TermMatch {
+	var matches []TermMatch
+	index := make(map[string]int)
+
+	offset := 0
+	rest := highlighted
+	for {
+		start := strings.Index(rest, explainOpen)
+		if start < 0 {
+			break
+		}
+		offset += len([]rune(rest[:start]))
+		rest = rest[start+len(explainOpen):]
+
+		end := strings.Index(rest, explainClose)
+		if end < 0 {
+			break
+		}
+		term := rest[:end]
+		rest = rest[end+len(explainClose):]
+
+		key := strings.ToLower(term)
+		if i, ok := index[key]; ok {
+			matches[i].Count++
+		} else {
+			index[key] = len(matches)
+			matches = append(matches, TermMatch{Column: column, Term: key, Count: 1, FirstOffset: offset})
+		}
+		offset += len([]rune(term))
+	}
+
+	return matches
+}
+
+// escapeLike escapes the LIKE wildcards in s, for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// FileExtensions returns the distinct lowercase file extensions of the indexed files
+func (db *DB) FileExtensions() ([]string, error) {
+	rows, err := db.Query("SELECT DISTINCT path FROM pdfs")
+	if err != nil {
+		return nil, fmt.Errorf("querying indexed paths: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	var extensions []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != "" && !seen[ext] {
+			seen[ext] = true
+			extensions = append(extensions, ext)
 		}
-		_, err := tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s;", triggerName))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(extensions)
+	return extensions, nil
+}
+
+// GetPageText returns the line-preserving text of a page, falling back to the
+// collapsed content for pages scanned before the original text was stored
+func (db *DB) GetPageText(filePath string, pageNum int) (string, error) {
+	var text string
+	err := db.QueryRow(
+		`
+			SELECT COALESCE(group_concat(text, char(10)), '') FROM (
+				SELECT COALESCE(NULLIF(original, ''), content, '') AS text FROM pdfs
+				WHERE path = ? AND COALESCE(real_page, page_num) = ?
+				ORDER BY page_num
+			)
+		`,
+		filePath, pageNum,
+	).Scan(&text)
+	if err != nil {
+		return "", fmt.Errorf("querying text of %s page %d: %w", filePath, pageNum, err)
+	}
+	return text, nil
+}
+
+// MatchingContent returns the content of the best ranked pages matching the query
+func (db *DB) MatchingContent(queryTerm string, limit int) ([]string, error) {
+	rows, err := db.Query(
+		`
+			SELECT COALESCE(p.content, '')
+			FROM pdfs_fts
+			JOIN pdfs AS p ON pdfs_fts.path = p.path AND pdfs_fts.page_num = p.page_num
+			WHERE pdfs_fts MATCH ? ORDER BY rank LIMIT ?;
+		`,
+		queryTerm, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contents []string
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// PageContent holds the stored content of a single page
+type PageContent struct {
+	PageNum int
+	Content string
+}
+
+// GetPageContent returns the content of the pages of a file in the given
+// inclusive page range, ordered by page number
+func (db *DB) GetPageContent(filePath string, fromPage, toPage int) ([]PageContent, error) {
+	rows, err := db.Query(
+		`
+			SELECT page, group_concat(content, ' ') FROM (
+				SELECT COALESCE(real_page, page_num) AS page, COALESCE(content, '') AS content
+				FROM pdfs
+				WHERE path = ? AND COALESCE(real_page, page_num) BETWEEN ? AND ?
+				ORDER BY page_num
+			)
+			GROUP BY page
+			ORDER BY page;
+		`,
+		filePath, fromPage, toPage,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying pages %d-%d of %s: %w", fromPage, toPage, filePath, err)
+	}
+	defer rows.Close()
+
+	var pages []PageContent
+	for rows.Next() {
+		var page PageContent
+		if err := rows.Scan(&page.PageNum, &page.Content); err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pages, nil
+}
+
+// Checkpoint copies the content of the WAL into the database file and truncates the WAL
+func (db *DB) Checkpoint() error {
+	if db.verbose {
+		log.Println("Checkpointing WAL...")
+	}
+
+	var busy, logFrames, checkpointedFrames int
+	if err := db.QueryRow("PRAGMA wal_checkpoint(TRUNCATE);").Scan(&busy, &logFrames, &checkpointedFrames); err != nil {
+		return fmt.Errorf("checkpointing WAL: %w", err)
+	}
+	if busy != 0 {
+		return fmt.Errorf("checkpointing WAL: database is busy")
+	}
+
+	return nil
+}
+
+// ftsTriggers are the names of the triggers keeping pdfs_fts in sync with pdfs
+var ftsTriggers = []string{"pdfs_after_insert", "pdfs_after_delete", "pdfs_after_update_content"}
+
+// dropTriggers removes the FTS sync triggers
+func (db *DB) dropTriggers(exec executor) error {
+	for _, triggerName := range ftsTriggers {
+		if db.verbose {
+			log.Printf("Dropping trigger %s if exists...", triggerName)
+		}
+		_, err := exec.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s;", triggerName))
 		if err != nil {
 			return fmt.Errorf("dropping trigger %s: %w", triggerName, err)
 		}
 	}
+	return nil
+}
+
+// DropTriggers stops keeping the FTS index in sync with the pdfs table, for
+// bulk imports. The index is stale until RebuildFTS restores the triggers
+// and repopulates it.
+func (db *DB) DropTriggers() error {
+	return db.dropTriggers(db.DB)
+}
+
+// DocInfo aggregates what is stored about an indexed document
+type DocInfo struct {
+	Path        string
+	Hash        string
+	Pages       int // pages of the document
+	Rows        int // stored rows, more than Pages when long pages were split in segments
+	EmptyPages  int // pages without any extracted text
+	Characters  int // extracted characters over all pages
+	LastScanned string
+	Volume      *Volume // set when the file is a volume of a larger document
+	PageOffset  int     // see SetPageOffset
+
+	// LowQualityPages lists the pages whose text quality is below the
+	// threshold given to DocumentInfo
+	LowQualityPages []int
+}
+
+// DocumentInfo returns the stored information about a document, or nil if it
+// isn't indexed
+func (db *DB) DocumentInfo(filePath string, lowQuality float64) (*DocInfo, error) {
+	info := &DocInfo{Path: filePath}
+	err := db.QueryRow(
+		`
+			SELECT
+				COALESCE(MAX(hash), ''),
+				COUNT(DISTINCT COALESCE(real_page, page_num)),
+				COUNT(*),
+				COALESCE(SUM(length(COALESCE(content, ''))), 0),
+				COALESCE(MAX(last_scanned), '')
+			FROM pdfs WHERE path = ?
+		`,
+		filePath,
+	).Scan(&info.Hash, &info.Pages, &info.Rows, &info.Characters, &info.LastScanned)
+	if err != nil {
+		return nil, fmt.Errorf("querying document %s: %w", filePath, err)
+	}
+	if info.Rows == 0 {
+		return nil, nil
+	}
+
+	err = db.QueryRow(
+		`
+			SELECT COUNT(*) FROM (
+				SELECT COALESCE(real_page, page_num) FROM pdfs WHERE path = ?
+				GROUP BY COALESCE(real_page, page_num)
+				HAVING SUM(length(COALESCE(content, ''))) = 0
+			)
+		`,
+		filePath,
+	).Scan(&info.EmptyPages)
+	if err != nil {
+		return nil, fmt.Errorf("counting empty pages of %s: %w", filePath, err)
+	}
+
+	rows, err := db.Query(
+		`
+			SELECT COALESCE(real_page, page_num) AS page FROM pdfs
+			WHERE path = ? AND length(COALESCE(content, '')) > 0
+			GROUP BY page
+			HAVING MIN(quality) < ?
+			ORDER BY page
+		`,
+		filePath, lowQuality,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying low quality pages of %s: %w", filePath, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var page int
+		if err := rows.Scan(&page); err != nil {
+			return nil, fmt.Errorf("scanning low quality page of %s: %w", filePath, err)
+		}
+		info.LowQualityPages = append(info.LowQualityPages, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying low quality pages of %s: %w", filePath, err)
+	}
+
+	var volume Volume
+	err = db.QueryRow(
+		"SELECT document, volume, page_offset FROM volumes WHERE path = ?",
+		filePath,
+	).Scan(&volume.Document, &volume.Number, &volume.PageOffset)
+	switch {
+	case err == nil:
+		info.Volume = &volume
+	case err != sql.ErrNoRows:
+		return nil, fmt.Errorf("querying volume of %s: %w", filePath, err)
+	}
+
+	if info.PageOffset, err = db.PageOffset(filePath); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// matchesPrefix reports whether path is prefix or a path inside it
+func matchesPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// IndexedPaths returns the distinct paths of the indexed files, optionally
+// only those equal to or under the given prefix
+func (db *DB) IndexedPaths(prefix string) ([]string, error) {
+	rows, err := db.Query("SELECT DISTINCT path FROM pdfs ORDER BY path")
+	if err != nil {
+		return nil, fmt.Errorf("querying indexed paths: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("scanning indexed path: %w", err)
+		}
+		if prefix == "" || matchesPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+	return paths, rows.Err()
+}
+
+// RemoveFiles removes the pages and raw text of the given files from the
+// index, e.g. after they were deleted from disk. Read marks and page offsets
+// are kept by path like for re-scans.
+func (db *DB) RemoveFiles(paths []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction for removal: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, path := range paths {
+		if _, err := tx.Exec("DELETE FROM pdfs WHERE path = ?", path); err != nil {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+		if _, err := tx.Exec("DELETE FROM raw_pages WHERE path = ?", path); err != nil {
+			return fmt.Errorf("removing raw text of %s: %w", path, err)
+		}
+		if _, err := tx.Exec("DELETE FROM sections WHERE path = ?", path); err != nil {
+			return fmt.Errorf("removing outline of %s: %w", path, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing removal: %w", err)
+	}
+	return nil
+}
+
+// RewritePathPrefix replaces the oldPrefix directory of the stored paths with
+// newPrefix, e.g. after the library was moved, keeping the extracted text. A
+// prefix naming a single file renames it. It returns the number of files
+// rewritten.
+func (db *DB) RewritePathPrefix(oldPrefix, newPrefix string) (int, error) {
+	oldPrefix = strings.TrimSuffix(oldPrefix, "/")
+	newPrefix = strings.TrimSuffix(newPrefix, "/")
+
+	paths, err := db.IndexedPaths(oldPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction for path rewrite: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Renaming a file changes its file name too, the files of a moved
+	// directory keep theirs
+	renamed := map[string]string{}
+	for _, oldPath := range paths {
+		newPath := newPrefix + strings.TrimPrefix(oldPath, oldPrefix)
+		if _, err := tx.Exec(
+			"UPDATE pdfs SET path = ?, path_key = ?, filename = ? WHERE path = ?",
+			newPath, util.FoldPath(newPath), filepath.Base(newPath), oldPath,
+		); err != nil {
+			return 0, fmt.Errorf("rewriting %s to %s: %w", oldPath, newPath, err)
+		}
+		if filepath.Base(newPath) != filepath.Base(oldPath) {
+			renamed[newPath] = filepath.Base(newPath)
+		}
+	}
+
+	// The FTS triggers only follow content changes, so the path stored in the
+	// index is rewritten too, in a single pass since it has no index on paths
+	for _, table := range []string{"pdfs_fts", "volumes", "read_status", "raw_pages", "page_offsets", "sections"} {
+		if _, err := tx.Exec(
+			"UPDATE "+table+" SET path = ? || substr(path, ?) WHERE path = ? OR substr(path, 1, ?) = ?",
+			newPrefix, len([]rune(oldPrefix))+1, oldPrefix, len([]rune(oldPrefix))+1, oldPrefix+"/",
+		); err != nil {
+			return 0, fmt.Errorf("rewriting paths in %s: %w", table, err)
+		}
+	}
+	// The update trigger ran before the index had the new paths
+	for newPath, filename := range renamed {
+		if _, err := tx.Exec("UPDATE pdfs_fts SET filename = ? WHERE path = ?", filename, newPath); err != nil {
+			return 0, fmt.Errorf("rewriting the file name of %s: %w", newPath, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing path rewrite: %w", err)
+	}
+	if len(paths) > 0 {
+		// Cached results still have the old paths
+		if err := db.BumpGeneration(); err != nil {
+			return 0, err
+		}
+	}
+	return len(paths), nil
+}
+
+// PageCount returns the number of indexed pages
+func (db *DB) PageCount() (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pdfs").Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting pages: %w", err)
+	}
+	return count, nil
+}
+
+// DocumentCount returns the number of indexed documents
+func (db *DB) DocumentCount() (int, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(DISTINCT path) FROM pdfs").Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting documents: %w", err)
+	}
+	return count, nil
+}
+
+// VerifyFTS checks that the FTS index is consistent: the FTS5 integrity check
+// passes and CheckFTS finds no problem. It returns an error describing the
+// first problem found.
+func (db *DB) VerifyFTS() error {
+	if _, err := db.Exec("INSERT INTO pdfs_fts(pdfs_fts) VALUES('integrity-check')"); err != nil {
+		return fmt.Errorf("FTS integrity check failed: %w", err)
+	}
+	_, err := db.CheckFTS()
+	return err
+}
+
+// CheckFTS is the quick part of VerifyFTS, cheap enough to run before every
+// command: the index has one row per stored page and its triggers are in
+// place. It returns the number of stored pages.
+func (db *DB) CheckFTS() (int, error) {
+	var pages, indexed int
+	if err := db.QueryRow("SELECT (SELECT COUNT(*) FROM pdfs), (SELECT COUNT(*) FROM pdfs_fts)").Scan(&pages, &indexed); err != nil {
+		return 0, fmt.Errorf("counting indexed pages: %w", err)
+	}
+	if pages != indexed {
+		return pages, fmt.Errorf("the index has %d row(s) for %d stored page(s)", indexed, pages)
+	}
+
+	for _, trigger := range ftsTriggers {
+		var exists bool
+		err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'trigger' AND name = ?)", trigger).Scan(&exists)
+		if err != nil {
+			return pages, fmt.Errorf("checking trigger %s: %w", trigger, err)
+		}
+		if !exists {
+			return pages, fmt.Errorf("trigger %s is missing, the index won't follow changes", trigger)
+		}
+	}
+	return pages, nil
+}
+
+// RebuildFTS drops and recreates the FTS index, returning the number of pages
+// repopulated. If onPage is not nil it is called with the path of each page
+// added to the index.
+func (db *DB) RebuildFTS(onPage func(path string)) (int, error) {
+	if db.verbose {
+		log.Println("Rebuilding Full-Text Search index...")
+	}
+
+	// The rank configured with SetRankWeights is lost with the table
+	rankWeights, err := db.GetMeta(rankWeightsMeta)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback if commit is not successful
+
+	// Drop triggers
+	if err := db.dropTriggers(tx); err != nil {
+		return 0, err
+	}
 
 	// Drop FTS table
 	if db.verbose {
@@ -269,17 +1834,22 @@ func (db *DB) RebuildFTS() error {
 	}
 	_, err = tx.Exec("DROP TABLE IF EXISTS pdfs_fts;")
 	if err != nil {
-		return fmt.Errorf("dropping pdfs_fts table: %w", err)
+		return 0, fmt.Errorf("dropping pdfs_fts table: %w", err)
 	}
 
 	// Recreate FTS table using helper
 	if err := db.createFTSTable(tx); err != nil {
-		return err // Error already formatted by helper
+		return 0, err // Error already formatted by helper
+	}
+	if rankWeights != "" {
+		if err := db.configureRank(tx, rankWeights); err != nil {
+			return 0, err
+		}
 	}
 
 	// Recreate triggers using helper
 	if err := db.createTriggers(tx); err != nil {
-		return err // Error already formatted by helper
+		return 0, err // Error already formatted by helper
 	}
 
 	// Repopulate FTS table
@@ -287,43 +1857,49 @@ func (db *DB) RebuildFTS() error {
 		log.Println("Repopulating FTS table from pdfs table...")
 	}
 	rows, err := tx.Query(`
-		SELECT path, page_num, content FROM pdfs;
+		SELECT path, page_num, COALESCE(filename, ''), content FROM pdfs;
 	`)
 	if err != nil {
-		return fmt.Errorf("querying pdfs table for repopulation: %w", err)
+		return 0, fmt.Errorf("querying pdfs table for repopulation: %w", err)
 	}
 	defer rows.Close()
 
 	insertStmt, err := tx.Prepare(`
-		INSERT INTO pdfs_fts (path, page_num, content_idx) VALUES (?, ?, ?);
+		INSERT INTO pdfs_fts (path, page_num, filename, content_idx) VALUES (?, ?, ?, ?);
 	`)
 	if err != nil {
-		return fmt.Errorf("preparing FTS insert statement: %w", err)
+		return 0, fmt.Errorf("preparing FTS insert statement: %w", err)
 	}
 	defer insertStmt.Close()
 
 	var repopulatedCount int
 	for rows.Next() {
-		var path, content string
+		var path, filename, content string
 		var pageNum int
-		if err := rows.Scan(&path, &pageNum, &content); err != nil {
+		if err := rows.Scan(&path, &pageNum, &filename, &content); err != nil {
 			log.Printf("Warning: Failed to scan row from pdfs table: %v. Skipping this row for FTS.", err)
 			continue
 		}
-		_, err := insertStmt.Exec(path, pageNum, content)
+		_, err := insertStmt.Exec(path, pageNum, filename, content)
 		if err != nil {
-			return fmt.Errorf("inserting into FTS table for %s page %d: %w", path, pageNum, err)
+			return 0, fmt.Errorf("inserting into FTS table for %s page %d: %w", path, pageNum, err)
 		}
 		repopulatedCount++
+		if onPage != nil {
+			onPage(path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("reading pdfs table for repopulation: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("committing FTS rebuild transaction: %w", err)
+		return 0, fmt.Errorf("committing FTS rebuild transaction: %w", err)
 	}
 
 	if db.verbose {
 		log.Printf("FTS rebuild completed successfully. Repopulated %d entries.", repopulatedCount)
 	}
 
-	return nil
+	return repopulatedCount, nil
 }
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestDB opens an empty in-memory database closed at the end of the test
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := New(MemoryPath, DefaultOptions())
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// storeDocument stores a document whose pages have the given content
+func storeDocument(t *testing.T, db *DB, path string, contents ...string) {
+	t.Helper()
+	pages := make([]Page, len(contents))
+	for i, content := range contents {
+		pages[i] = Page{Content: content, Original: content}
+	}
+	if err := db.UpsertPDFData(path, "hash-"+path, pages, 0); err != nil {
+		t.Fatalf("storing %s: %v", path, err)
+	}
+}
+
+// matchingPages returns the pages of path whose indexed content matches query
+func matchingPages(t *testing.T, db *DB, path, query string) []int {
+	t.Helper()
+	rows, err := db.Query("SELECT page_num FROM pdfs_fts WHERE pdfs_fts MATCH ? AND path = ? ORDER BY page_num", query, path)
+	if err != nil {
+		t.Fatalf("querying index: %v", err)
+	}
+	defer rows.Close()
+
+	var pages []int
+	for rows.Next() {
+		var page int
+		if err := rows.Scan(&page); err != nil {
+			t.Fatalf("scanning page: %v", err)
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+func TestUpsertPDFData(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    []string
+		after     []string
+		batchSize int
+		query     string
+		want      []int // pages matching query after the update
+	}{
+		{
+			name:   "changed page is reindexed",
+			before: []string{"alpha page", "bravo page", "charlie page"},
+			after:  []string{"alpha page", "delta page", "charlie page"},
+			query:  "delta",
+			want:   []int{2},
+		},
+		{
+			name:   "old content is removed from the index",
+			before: []string{"alpha page", "bravo page", "charlie page"},
+			after:  []string{"alpha page", "delta page", "charlie page"},
+			query:  "bravo",
+			want:   nil,
+		},
+		{
+			name:   "trailing pages are deleted",
+			before: []string{"alpha page", "bravo page", "charlie page"},
+			after:  []string{"alpha page"},
+			query:  "page",
+			want:   []int{1},
+		},
+		{
+			name:      "batched upsert",
+			before:    []string{"alpha page", "bravo page", "charlie page"},
+			after:     []string{"echo page", "foxtrot page", "golf page", "hotel page", "india page"},
+			batchSize: 2,
+			query:     "page",
+			want:      []int{1, 2, 3, 4, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			storeDocument(t, db, "doc.pdf", tt.before...)
+
+			pages := make([]Page, len(tt.after))
+			for i, content := range tt.after {
+				pages[i] = Page{Content: content, Original: content}
+			}
+			if err := db.UpsertPDFData("doc.pdf", "new-hash", pages, tt.batchSize); err != nil {
+				t.Fatalf("updating: %v", err)
+			}
+
+			if got := matchingPages(t, db, "doc.pdf", tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pages matching %q: got %v, want %v", tt.query, got, tt.want)
+			}
+			if hash, err := db.GetStoredHash("doc.pdf"); err != nil || hash != "new-hash" {
+				t.Errorf("stored hash: got %q (%v), want new-hash", hash, err)
+			}
+			if err := db.VerifyFTS(); err != nil {
+				t.Errorf("index inconsistent with the pages: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fts.db")
+	db, err := New(path, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeDocument(t, db, "doc.pdf", "alpha page")
+	// An older data version doesn't matter to read-only opens
+	if err := db.SetMeta("schema_version", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DELETE FROM meta WHERE key = 'layout_version'"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := Validate(path); err == nil {
+		t.Error("Validate accepted a database that wasn't migrated")
+	}
+	if err := ValidateMigratable(path); err != nil {
+		t.Errorf("ValidateMigratable: %v", err)
+	}
+
+	// Opening it read-write migrates it
+	db, err = New(path, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if err := Validate(path); err != nil {
+		t.Errorf("Validate after migrating: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"fmt"
+)
+
+// Orders of ListDocuments
+const (
+	ListByPath  = "path"  // alphabetically
+	ListBySize  = "size"  // most extracted characters first
+	ListByPages = "pages" // most pages first
+)
+
+// DocumentSummary is the stored size of an indexed document
+type DocumentSummary struct {
+	Path        string
+	Hash        string
+	Pages       int // pages of the document, up to the last one stored
+	TextPages   int // pages with extracted text, fewer than Pages for blank or scanned pages
+	Characters  int // extracted characters over all pages
+	LastScanned string
+}
+
+// ListDocuments returns every indexed document in the given order, ListByPath,
+// ListBySize or ListByPages. A limit of zero or less returns all of them.
+func (db *DB) ListDocuments(order string, limit int) ([]DocumentSummary, error) {
+	orderBy := "path"
+	switch order {
+	case ListByPath:
+	case ListBySize:
+		orderBy = "characters DESC, path"
+	case ListByPages:
+		orderBy = "pages DESC, path"
+	default:
+		return nil, fmt.Errorf("unknown document order %q", order)
+	}
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+
+	rows, err := db.Query(
+		`
+			SELECT
+				path,
+				COALESCE(MAX(hash), ''),
+				MAX(COALESCE(real_page, page_num)) AS pages,
+				COUNT(DISTINCT CASE WHEN length(COALESCE(content, '')) > 0 THEN COALESCE(real_page, page_num) END),
+				COALESCE(SUM(length(COALESCE(content, ''))), 0) AS characters,
+				COALESCE(MAX(last_scanned), '')
+			FROM pdfs
+			GROUP BY path
+			ORDER BY `+orderBy+`
+			LIMIT ?
+		`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing documents: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []DocumentSummary
+	for rows.Next() {
+		var doc DocumentSummary
+		if err := rows.Scan(&doc.Path, &doc.Hash, &doc.Pages, &doc.TextPages, &doc.Characters, &doc.LastScanned); err != nil {
+			return nil, fmt.Errorf("scanning document: %w", err)
+		}
+		documents = append(documents, doc)
+	}
+	return documents, rows.Err()
+}
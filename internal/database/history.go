@@ -0,0 +1,75 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// createScanHistoryTable creates the table keeping one row per scan run
+func (db *DB) createScanHistoryTable() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS scan_history (
+			id INTEGER PRIMARY KEY,
+			started_at DATETIME NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			found INTEGER NOT NULL,
+			processed INTEGER NOT NULL,
+			failed INTEGER NOT NULL,
+			bytes INTEGER NOT NULL
+		);
+	`); err != nil {
+		return fmt.Errorf("creating scan_history table: %w", err)
+	}
+	return nil
+}
+
+// ScanRun is the summary of a scan run
+type ScanRun struct {
+	StartedAt time.Time
+	Duration  time.Duration
+	Found     int   // PDF files discovered
+	Processed int   // files extracted and stored
+	Failed    int   // files that couldn't be hashed, extracted or stored
+	Bytes     int64 // size of the files that needed processing
+}
+
+// RecordScanRun appends a scan run to the history
+func (db *DB) RecordScanRun(run ScanRun) error {
+	_, err := db.Exec(
+		"INSERT INTO scan_history (started_at, duration_ms, found, processed, failed, bytes) VALUES (?, ?, ?, ?, ?, ?)",
+		run.StartedAt.UTC(), run.Duration.Milliseconds(), run.Found, run.Processed, run.Failed, run.Bytes,
+	)
+	if err != nil {
+		return fmt.Errorf("recording scan run: %w", err)
+	}
+	return nil
+}
+
+// ScanRuns returns the most recent scan runs, newest first. A limit of zero or
+// less returns all of them.
+func (db *DB) ScanRuns(limit int) ([]ScanRun, error) {
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+
+	rows, err := db.Query(
+		"SELECT started_at, duration_ms, found, processed, failed, bytes FROM scan_history ORDER BY id DESC LIMIT ?",
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying scan history: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []ScanRun
+	for rows.Next() {
+		var run ScanRun
+		var durationMs int64
+		if err := rows.Scan(&run.StartedAt, &durationMs, &run.Found, &run.Processed, &run.Failed, &run.Bytes); err != nil {
+			return nil, fmt.Errorf("scanning scan run: %w", err)
+		}
+		run.Duration = time.Duration(durationMs) * time.Millisecond
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// createPageOffsetsTable creates the table of the page offsets set with
+// SetPageOffset. Like read_status it is keyed by path and untouched by scans.
+func (db *DB) createPageOffsetsTable() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS page_offsets (
+			path TEXT PRIMARY KEY,
+			page_offset INTEGER NOT NULL
+		);
+	`); err != nil {
+		return fmt.Errorf("creating page_offsets table: %w", err)
+	}
+	return nil
+}
+
+// SetPageOffset sets the number of pages preceding page 1 of the numbering
+// printed in an indexed file, like its front matter. An offset of zero
+// removes it, a negative one numbers the first page after 1. The offset must
+// be smaller than the number of pages of the file.
+func (db *DB) SetPageOffset(path string, offset int) error {
+	if offset == 0 {
+		if _, err := db.Exec("DELETE FROM page_offsets WHERE path = ?", path); err != nil {
+			return fmt.Errorf("removing page offset of %s: %w", path, err)
+		}
+		return db.BumpGeneration()
+	}
+
+	var pages int
+	if err := db.QueryRow("SELECT COALESCE(MAX(COALESCE(real_page, page_num)), 0) FROM pdfs WHERE path = ?", path).Scan(&pages); err != nil {
+		return fmt.Errorf("checking %s: %w", path, err)
+	}
+	if pages == 0 {
+		return fmt.Errorf("file %s is not indexed", path)
+	}
+	// Page 1 of the printed numbering must be one of the pages of the file
+	if offset >= pages {
+		return fmt.Errorf("page offset %d is too large for %s, it has %d page(s)", offset, path, pages)
+	}
+	if _, err := db.Exec("INSERT OR REPLACE INTO page_offsets (path, page_offset) VALUES (?, ?)", path, offset); err != nil {
+		return fmt.Errorf("setting page offset of %s: %w", path, err)
+	}
+	// Search results carry the offset, cached ones are stale
+	return db.BumpGeneration()
+}
+
+// PageOffset returns the page offset of a file, zero when not set
+func (db *DB) PageOffset(path string) (int, error) {
+	var offset int
+	err := db.QueryRow("SELECT COALESCE((SELECT page_offset FROM page_offsets WHERE path = ?), 0)", path).Scan(&offset)
+	if err != nil {
+		return 0, fmt.Errorf("querying page offset of %s: %w", path, err)
+	}
+	return offset, nil
+}
+
+// PrintedPage returns the number printed on a page (starting at 1) of a file
+// with the given page offset. Pages before page 1, the front matter, are
+// numbered with lowercase roman numerals.
+func PrintedPage(pageNum, offset int) string {
+	if pageNum > offset {
+		return strconv.Itoa(pageNum - offset)
+	}
+	return romanNumeral(pageNum)
+}
+
+// romanNumeral formats a positive number as a lowercase roman numeral
+func romanNumeral(n int) string {
+	numerals := []struct {
+		value  int
+		symbol string
+	}{
+		{1000, "m"}, {900, "cm"}, {500, "d"}, {400, "cd"},
+		{100, "c"}, {90, "xc"}, {50, "l"}, {40, "xl"},
+		{10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"},
+	}
+
+	var b strings.Builder
+	for _, numeral := range numerals {
+		for n >= numeral.value {
+			b.WriteString(numeral.symbol)
+			n -= numeral.value
+		}
+	}
+	return b.String()
+}
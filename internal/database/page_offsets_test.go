@@ -0,0 +1,37 @@
+package database
+
+import "testing"
+
+func TestSetPageOffset(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "docs/one.pdf", "cover")
+	storeDocument(t, db, "docs/three.pdf", "cover", "preface", "chapter one")
+
+	tests := []struct {
+		path    string
+		offset  int
+		wantErr bool
+	}{
+		{"docs/three.pdf", 2, false},
+		{"docs/three.pdf", -1, false},
+		{"docs/three.pdf", 0, false},
+		{"docs/three.pdf", 3, true},
+		{"docs/one.pdf", 1, true},
+		{"docs/one.pdf", 2, true},
+		{"docs/missing.pdf", 1, true},
+	}
+
+	for _, tt := range tests {
+		err := db.SetPageOffset(tt.path, tt.offset)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SetPageOffset(%s, %d): got error %v, want error %v", tt.path, tt.offset, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if offset, err := db.PageOffset(tt.path); err != nil || offset != tt.offset {
+			t.Errorf("PageOffset(%s): got %d (%v), want %d", tt.path, offset, err, tt.offset)
+		}
+	}
+}
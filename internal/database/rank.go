@@ -0,0 +1,77 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rankWeightsMeta is the meta key recording the weights set with
+// SetRankWeights, as "filename,content"
+const rankWeightsMeta = "rank_weights"
+
+// RankWeights returns the bm25 column weights used when a search doesn't pass
+// its own, the ones set with SetRankWeights or else the default ones. The
+// last value reports whether they were set.
+func (db *DB) RankWeights() (filename, content float64, set bool, err error) {
+	value, err := db.GetMeta(rankWeightsMeta)
+	if err != nil || value == "" {
+		return DefaultFilenameWeight, DefaultContentWeight, false, err
+	}
+
+	filenameText, contentText, _ := strings.Cut(value, ",")
+	filename, err = strconv.ParseFloat(filenameText, 64)
+	if err == nil {
+		content, err = strconv.ParseFloat(contentText, 64)
+	}
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("parsing rank weights %q: %w", value, err)
+	}
+	return filename, content, true, nil
+}
+
+// SetRankWeights stores the default bm25 weights of matches in the file name
+// and in the page content, and sets them as the rank of the FTS table, so
+// queries ordering by rank use them too
+func (db *DB) SetRankWeights(filename, content float64) error {
+	if filename < 0 || content < 0 || filename == 0 && content == 0 {
+		return fmt.Errorf("rank weights must not be negative, and not both zero")
+	}
+
+	value := strconv.FormatFloat(filename, 'g', -1, 64) + "," + strconv.FormatFloat(content, 'g', -1, 64)
+	if err := db.SetMeta(rankWeightsMeta, value); err != nil {
+		return err
+	}
+	if err := db.configureRank(db.DB, value); err != nil {
+		return err
+	}
+	// Cached results were ranked with the previous weights
+	return db.BumpGeneration()
+}
+
+// ResetRankWeights removes the weights set with SetRankWeights, restoring
+// the default ones and the default rank of the FTS table
+func (db *DB) ResetRankWeights() error {
+	if _, err := db.Exec("DELETE FROM meta WHERE key = ?", rankWeightsMeta); err != nil {
+		return fmt.Errorf("removing rank weights: %w", err)
+	}
+	if err := db.configureRank(db.DB, ""); err != nil {
+		return err
+	}
+	return db.BumpGeneration()
+}
+
+// configureRank sets the rank function of the FTS table to bm25 with the
+// weights stored as "filename,content", or to plain bm25 when empty. The
+// unindexed path and page_num columns get no weight. The setting is stored in
+// the table, so it is only lost when the table is recreated.
+func (db *DB) configureRank(exec executor, weights string) error {
+	rank := "bm25()"
+	if weights != "" {
+		rank = "bm25(0, 0, " + strings.ReplaceAll(weights, ",", ", ") + ")"
+	}
+	if _, err := exec.Exec("INSERT INTO pdfs_fts (pdfs_fts, rank) VALUES ('rank', ?)", rank); err != nil {
+		return fmt.Errorf("configuring FTS rank: %w", err)
+	}
+	return nil
+}
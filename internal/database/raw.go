@@ -0,0 +1,99 @@
+package database
+
+import "fmt"
+
+// RawPage is the text of a page as extracted, before cleaning, stored so the
+// cleaning rules can be applied again without reading the PDF
+type RawPage struct {
+	Text        string
+	Annotations string
+}
+
+// createRawPagesTable creates the table holding the raw text of the documents
+// scanned with --store-raw, one row per page of the document
+func (db *DB) createRawPagesTable() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS raw_pages (
+			path TEXT NOT NULL,
+			page_num INTEGER NOT NULL,
+			raw TEXT NOT NULL,
+			annotations TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (path, page_num)
+		);
+	`); err != nil {
+		return fmt.Errorf("creating raw_pages table: %w", err)
+	}
+	return nil
+}
+
+// StoreRawPages replaces the raw text stored for a document, with no pages it
+// only removes the previous one so it can't go stale
+func (db *DB) StoreRawPages(filePath string, pages []RawPage) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction for raw text of %s: %w", filePath, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM raw_pages WHERE path = ?", filePath); err != nil {
+		return fmt.Errorf("deleting raw text of %s: %w", filePath, err)
+	}
+
+	if len(pages) > 0 {
+		stmt, err := tx.Prepare("INSERT INTO raw_pages (path, page_num, raw, annotations) VALUES (?, ?, ?, ?)")
+		if err != nil {
+			return fmt.Errorf("preparing raw text insert for %s: %w", filePath, err)
+		}
+		defer stmt.Close()
+
+		for i, page := range pages {
+			if _, err := stmt.Exec(filePath, i+1, page.Text, page.Annotations); err != nil {
+				return fmt.Errorf("storing raw text of page %d of %s: %w", i+1, filePath, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing raw text of %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// RawPaths returns the documents with stored raw text
+func (db *DB) RawPaths() ([]string, error) {
+	rows, err := db.Query("SELECT DISTINCT path FROM raw_pages ORDER BY path")
+	if err != nil {
+		return nil, fmt.Errorf("querying raw text paths: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("scanning raw text path: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// RawPages returns the stored raw text of a document in page order, or nil
+// if it was not scanned with --store-raw
+func (db *DB) RawPages(filePath string) ([]RawPage, error) {
+	rows, err := db.Query("SELECT raw, annotations FROM raw_pages WHERE path = ? ORDER BY page_num", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("querying raw text of %s: %w", filePath, err)
+	}
+	defer rows.Close()
+
+	var pages []RawPage
+	for rows.Next() {
+		var page RawPage
+		if err := rows.Scan(&page.Text, &page.Annotations); err != nil {
+			return nil, fmt.Errorf("scanning raw text of %s: %w", filePath, err)
+		}
+		pages = append(pages, page)
+	}
+	return pages, rows.Err()
+}
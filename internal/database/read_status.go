@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// createReadStatusTable creates the table tracking which documents were read.
+// It is keyed by path and untouched by scans, so marks survive re-indexing.
+func (db *DB) createReadStatusTable() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS read_status (
+			path TEXT PRIMARY KEY,
+			read_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`); err != nil {
+		return fmt.Errorf("creating read_status table: %w", err)
+	}
+	return nil
+}
+
+// MarkRead marks the given indexed files as read, or as unread when read is false
+func (db *DB) MarkRead(paths []string, read bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction for read status: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, path := range paths {
+		if !read {
+			if _, err := tx.Exec("DELETE FROM read_status WHERE path = ?", path); err != nil {
+				return fmt.Errorf("marking %s as unread: %w", path, err)
+			}
+			continue
+		}
+
+		var indexed bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM pdfs WHERE path = ?)", path).Scan(&indexed); err != nil {
+			return fmt.Errorf("checking %s: %w", path, err)
+		}
+		if !indexed {
+			return fmt.Errorf("file %s is not indexed", path)
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO read_status (path, read_at) VALUES (?, CURRENT_TIMESTAMP)", path); err != nil {
+			return fmt.Errorf("marking %s as read: %w", path, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing read status: %w", err)
+	}
+	return nil
+}
+
+// ReadDocument is a document marked as read
+type ReadDocument struct {
+	Path   string
+	ReadAt time.Time
+}
+
+// ReadDocuments returns the documents marked as read, most recent first
+func (db *DB) ReadDocuments() ([]ReadDocument, error) {
+	rows, err := db.Query("SELECT path, read_at FROM read_status ORDER BY read_at DESC, path")
+	if err != nil {
+		return nil, fmt.Errorf("querying read documents: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []ReadDocument
+	for rows.Next() {
+		var document ReadDocument
+		if err := rows.Scan(&document.Path, &document.ReadAt); err != nil {
+			return nil, fmt.Errorf("scanning read document: %w", err)
+		}
+		documents = append(documents, document)
+	}
+	return documents, rows.Err()
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// generationMeta is the meta key counting the writes that change search results
+const generationMeta = "generation"
+
+// Generation returns the number of times the indexed data was changed by a
+// scan, used to invalidate cached search results
+func (db *DB) Generation() (int64, error) {
+	value, err := db.GetMeta(generationMeta)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	generation, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing generation %q: %w", value, err)
+	}
+	return generation, nil
+}
+
+// BumpGeneration records that the indexed data changed, invalidating the
+// results cached by every SearchCache on the database, even in other processes
+func (db *DB) BumpGeneration() error {
+	_, err := db.Exec(
+		`
+			INSERT INTO meta (key, value) VALUES (?, '1')
+			ON CONFLICT (key) DO UPDATE SET value = CAST(value AS INTEGER) + 1
+		`,
+		generationMeta,
+	)
+	if err != nil {
+		return fmt.Errorf("bumping generation: %w", err)
+	}
+	return nil
+}
+
+// SearchCache wraps Search with a small LRU cache of the results of recent
+// queries, for interactive or long-running searchers repeating the same
+// queries. Entries expire after a TTL and are dropped when the database
+// generation changes. The returned slices are shared and must not be modified.
+type SearchCache struct {
+	db   *DB
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+type searchCacheEntry struct {
+	key        string
+	generation int64
+	stored     time.Time
+	results    []SearchResult
+}
+
+// NewSearchCache creates a cache of up to size queries kept for ttl. A size
+// of zero or less disables caching.
+func NewSearchCache(db *DB, size int, ttl time.Duration) *SearchCache {
+	return &SearchCache{
+		db:      db,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Search returns the cached results of the query, running it with db.Search
+// when they are missing, expired or from an older generation
+func (c *SearchCache) Search(queryTerm string, opts SearchOptions) ([]SearchResult, error) {
+	if c.size <= 0 {
+		return c.db.Search(queryTerm, opts)
+	}
+
+	generation, err := c.db.Generation()
+	if err != nil {
+		return nil, err
+	}
+
+	// SearchOptions only holds plain values and slices of strings
+	key := fmt.Sprintf("%q %#v", queryTerm, opts)
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*searchCacheEntry)
+		if entry.generation == generation && time.Since(entry.stored) < c.ttl {
+			c.order.MoveToFront(element)
+			c.mu.Unlock()
+			return entry.results, nil
+		}
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	results, err := c.db.Search(queryTerm, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		// Stored by a concurrent search of the same query meanwhile
+		c.order.Remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&searchCacheEntry{
+		key:        key,
+		generation: generation,
+		stored:     time.Now(),
+		results:    results,
+	})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*searchCacheEntry).key)
+	}
+	return results, nil
+}
@@ -0,0 +1,336 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// searchResults returns the results of a search as "path:page"
+func searchResults(t *testing.T, db *DB, query string, opts SearchOptions) []string {
+	t.Helper()
+	results, err := db.Search(query, opts)
+	if err != nil {
+		t.Fatalf("searching %q: %v", query, err)
+	}
+	var got []string
+	for _, result := range results {
+		got = append(got, fmt.Sprintf("%s:%d", result.Path, result.PageNum))
+	}
+	return got
+}
+
+func TestSearchRankWeights(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "docs/report.pdf", "a summary of the quarter")
+	storeDocument(t, db, "docs/notes.pdf", "report report report, the report is late")
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{"default weights favor the file name", SearchOptions{}, []string{"docs/report.pdf:1", "docs/notes.pdf:1"}},
+		{"content weight", SearchOptions{FilenameWeight: 0.01, ContentWeight: 1}, []string{"docs/notes.pdf:1", "docs/report.pdf:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchResults(t, db, "report", tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchDistinctFiles(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "a.pdf", "one apple here among many other words", "apple apple apple", "no fruit")
+	storeDocument(t, db, "b.pdf", "apple apple", "apple")
+	storeDocument(t, db, "c.pdf", "an apple a day keeps the doctor away")
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{"best page of each file", SearchOptions{DistinctFiles: true}, []string{"a.pdf:2", "b.pdf:1", "c.pdf:1"}},
+		{"limit counts files", SearchOptions{DistinctFiles: true, Limit: 2}, []string{"a.pdf:2", "b.pdf:1"}},
+		{"offset counts files", SearchOptions{DistinctFiles: true, Limit: 2, Offset: 2}, []string{"c.pdf:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchResults(t, db, "apple", tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchLimitFiles(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "a.pdf", "apple apple apple", "apple", "no fruit")
+	storeDocument(t, db, "b.pdf", "apple apple", "apple, then other words")
+	storeDocument(t, db, "c.pdf", "an apple a day keeps the doctor away")
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{"every page of the best files", SearchOptions{LimitFiles: true, Limit: 2}, []string{"a.pdf:1", "a.pdf:2", "b.pdf:1", "b.pdf:2"}},
+		{"offset skips files", SearchOptions{LimitFiles: true, Limit: 1, Offset: 1}, []string{"b.pdf:1", "b.pdf:2"}},
+		{"no limit", SearchOptions{LimitFiles: true}, []string{"a.pdf:1", "a.pdf:2", "b.pdf:1", "b.pdf:2", "c.pdf:1"}},
+		{"limit counts pages without it", SearchOptions{Limit: 2}, []string{"a.pdf:1", "b.pdf:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchResults(t, db, "apple", tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchMaxRank(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "strong.pdf", "apple apple apple apple")
+	storeDocument(t, db, "weak.pdf", "apple among a long page of many other unrelated words about nothing at all")
+
+	all, err := db.Search("apple", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Score >= all[1].Score {
+		t.Fatalf("expected two results with distinct scores, got %+v", all)
+	}
+	between := (all[0].Score + all[1].Score) / 2
+
+	tests := []struct {
+		name    string
+		maxRank float64
+		want    []string
+	}{
+		{"disabled", 0, []string{"strong.pdf:1", "weak.pdf:1"}},
+		{"drops weak matches", between, []string{"strong.pdf:1"}},
+		{"keeps matches at the threshold", all[1].Score, []string{"strong.pdf:1", "weak.pdf:1"}},
+		{"drops everything", all[0].Score - 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchResults(t, db, "apple", SearchOptions{MaxRank: tt.maxRank}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplainMatchScore(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "docs/report.pdf", "the report of the quarter")
+	storeDocument(t, db, "docs/notes.pdf", "report report, the report is late")
+
+	tests := []struct {
+		name        string
+		opts        SearchOptions
+		rankWeights []float64 // set with SetRankWeights first
+	}{
+		{name: "default weights"},
+		{name: "search weights", opts: SearchOptions{FilenameWeight: 0.5, ContentWeight: 4}},
+		{name: "stored weights", rankWeights: []float64{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.rankWeights != nil {
+				if err := db.SetRankWeights(tt.rankWeights[0], tt.rankWeights[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			results, err := db.Search("report", tt.opts)
+			if err != nil || len(results) != 2 {
+				t.Fatalf("got %d results (%v), want 2", len(results), err)
+			}
+			for _, result := range results {
+				explanation, err := db.ExplainMatch("report", result.Path, result.PageNum, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if explanation.Score != result.Score {
+					t.Errorf("%s: explained score %v, search score %v", result.Path, explanation.Score, result.Score)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchEachBatches(t *testing.T) {
+	db := newTestDB(t)
+	files := 2*searchBatchSize + 10
+	for i := range files {
+		storeDocument(t, db, fmt.Sprintf("doc%04d.pdf", i), "apple", "apple pie")
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want int
+	}{
+		{"all pages", SearchOptions{}, 2 * files},
+		{"limit across batches", SearchOptions{Limit: searchBatchSize + 5}, searchBatchSize + 5},
+		{"offset across batches", SearchOptions{Offset: 2*files - 3}, 3},
+		{"all files", SearchOptions{LimitFiles: true}, 2 * files},
+		{"files across batches", SearchOptions{LimitFiles: true, Limit: searchBatchSize + 1}, 2 * (searchBatchSize + 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchResults(t, db, "apple", tt.opts)
+			if len(got) != tt.want {
+				t.Fatalf("got %d results, want %d", len(got), tt.want)
+			}
+			seen := make(map[string]bool)
+			for _, result := range got {
+				if seen[result] {
+					t.Fatalf("%s returned twice", result)
+				}
+				seen[result] = true
+			}
+		})
+	}
+}
+
+func TestSearchEachNestedQueries(t *testing.T) {
+	// A pool of a single connection deadlocks if rows are open during callbacks
+	opts := DefaultOptions()
+	opts.MaxOpenConns = 1
+	db, err := New(MemoryPath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	storeDocument(t, db, "a.pdf", "apple", "apple pie")
+
+	pages := 0
+	err = db.SearchEach("apple", SearchOptions{}, func(result SearchResult) error {
+		if _, err := db.GetPageText(result.Path, result.PageNum); err != nil {
+			return err
+		}
+		pages++
+		return nil
+	})
+	if err != nil || pages != 2 {
+		t.Errorf("got %d pages (%v), want 2", pages, err)
+	}
+}
+
+func TestSearchPageRange(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "a.pdf", "apple", "apple", "apple", "apple")
+	// Segments of a long page keep the number of the page they come from
+	if err := db.UpsertPDFData("b.pdf", "hash-b", []Page{
+		{Content: "apple", PageNum: 1},
+		{Content: "apple", PageNum: 2},
+		{Content: "apple", PageNum: 2},
+	}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to int
+		want     int
+	}{
+		{"no range", 0, 0, 7},
+		{"single page", 2, 2, 3},
+		{"closed range", 2, 3, 4},
+		{"open end", 3, 0, 2},
+		{"open start", 0, 1, 2},
+		{"past the end", 5, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchResults(t, db, "apple", SearchOptions{FromPage: tt.from, ToPage: tt.to})
+			if len(got) != tt.want {
+				t.Errorf("got %v, want %d results", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchDottedTerms(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "docs/v12.pdf", "release v1.2 on 2023-01-15 for amd64/linux")
+	storeDocument(t, db, "docs/v13.pdf", "release v1.3 on 2023-02-01 for arm64/linux")
+
+	// The queries are quoted like the search command does for dotted words
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`"v1.2"`, []string{"docs/v12.pdf:1"}},
+		{`"2023-01-15"`, []string{"docs/v12.pdf:1"}},
+		{`"amd64/linux"`, []string{"docs/v12.pdf:1"}},
+		{`"v1.3" AND release`, []string{"docs/v13.pdf:1"}},
+		{`"2023-01"*`, []string{"docs/v12.pdf:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := searchResults(t, db, tt.query, SearchOptions{})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			pages, docs, err := db.CountMatches(tt.query)
+			if err != nil || pages != len(tt.want) || docs != len(tt.want) {
+				t.Errorf("counted %d pages in %d documents (%v), want %d", pages, docs, err, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestRewritePathPrefix(t *testing.T) {
+	db := newTestDB(t)
+	storeDocument(t, db, "docs/good.pdf", "a page about wombats")
+	storeDocument(t, db, "docs/other.pdf", "a page about quokkas")
+
+	steps := []struct {
+		from, to string
+		queries  map[string][]string
+	}{
+		{
+			from: "docs/good.pdf",
+			to:   "docs/renamed.pdf",
+			queries: map[string][]string{
+				"good":    nil,
+				"renamed": {"docs/renamed.pdf:1"},
+				"other":   {"docs/other.pdf:1"},
+			},
+		},
+		{
+			// Files of a moved directory keep their names
+			from: "docs",
+			to:   "library",
+			queries: map[string][]string{
+				"renamed": {"library/renamed.pdf:1"},
+				"other":   {"library/other.pdf:1"},
+				"docs":    nil,
+			},
+		},
+	}
+
+	for _, step := range steps {
+		if _, err := db.RewritePathPrefix(step.from, step.to); err != nil {
+			t.Fatalf("rewriting %s to %s: %v", step.from, step.to, err)
+		}
+		for query, want := range step.queries {
+			if got := searchResults(t, db, query, SearchOptions{}); !reflect.DeepEqual(got, want) {
+				t.Errorf("after rewriting %s to %s, %q: got %v, want %v", step.from, step.to, query, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package database
+
+import "fmt"
+
+// Section is an entry of the outline of a document with the pages it spans
+type Section struct {
+	Level    int
+	Title    string
+	FromPage int
+	ToPage   int
+}
+
+// createSectionsTable creates the table holding the outline of the documents,
+// used to restrict searches to a section
+func (db *DB) createSectionsTable() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS sections (
+			path TEXT NOT NULL,
+			entry INTEGER NOT NULL,
+			level INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			from_page INTEGER NOT NULL,
+			to_page INTEGER NOT NULL,
+			PRIMARY KEY (path, entry)
+		);
+	`); err != nil {
+		return fmt.Errorf("creating sections table: %w", err)
+	}
+	return nil
+}
+
+// StoreSections replaces the outline stored for a document, with no sections
+// it only removes the previous one
+func (db *DB) StoreSections(filePath string, sections []Section) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction for outline of %s: %w", filePath, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM sections WHERE path = ?", filePath); err != nil {
+		return fmt.Errorf("deleting outline of %s: %w", filePath, err)
+	}
+
+	if len(sections) > 0 {
+		stmt, err := tx.Prepare("INSERT INTO sections (path, entry, level, title, from_page, to_page) VALUES (?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			return fmt.Errorf("preparing outline insert for %s: %w", filePath, err)
+		}
+		defer stmt.Close()
+
+		for i, section := range sections {
+			if _, err := stmt.Exec(filePath, i+1, section.Level, section.Title, section.FromPage, section.ToPage); err != nil {
+				return fmt.Errorf("storing outline entry %q of %s: %w", section.Title, filePath, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing outline of %s: %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// volumePattern matches file names ending with a separated volume keyword and
+// number, like "Book vol2", "book_volume_02", "book-part3" or "book vol. 1"
+var volumePattern = regexp.MustCompile(`(?i)^(.+?)[\s_.-]+(?:vol(?:ume)?|part)[\s_.-]*(\d+)$`)
+
+// Volume places a file inside a logical document split across several files
+type Volume struct {
+	Document string
+	Number   int
+	// PageOffset is the number of pages of the previous volumes
+	PageOffset int
+}
+
+// DocumentPage returns the page of the logical document for a page of the volume
+func (v Volume) DocumentPage(pageNum int) int {
+	return v.PageOffset + pageNum
+}
+
+// createVolumesTable creates the table grouping files into logical documents.
+// Rows with manual set are created with SetVolumes and are never regrouped
+// automatically.
+func (db *DB) createVolumesTable() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS volumes (
+			path TEXT PRIMARY KEY,
+			document TEXT NOT NULL,
+			volume INTEGER NOT NULL,
+			page_offset INTEGER NOT NULL DEFAULT 0,
+			manual INTEGER NOT NULL DEFAULT 0
+		);
+	`); err != nil {
+		return fmt.Errorf("creating volumes table: %w", err)
+	}
+	return nil
+}
+
+// pageCounts returns the number of indexed pages of each file
+func (db *DB) pageCounts() (map[string]int, error) {
+	rows, err := db.Query("SELECT path, MAX(COALESCE(real_page, page_num)) FROM pdfs GROUP BY path")
+	if err != nil {
+		return nil, fmt.Errorf("querying page counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var path string
+		var count int
+		if err := rows.Scan(&path, &count); err != nil {
+			return nil, fmt.Errorf("scanning page count: %w", err)
+		}
+		counts[path] = count
+	}
+	return counts, rows.Err()
+}
+
+// GroupVolumes groups the files in the same directory whose names differ only
+// by a volume number (e.g. "book vol1.pdf" and "book vol2.pdf") into logical
+// documents, replacing the previous automatic grouping. Files grouped with
+// SetVolumes are left untouched. It returns the number of documents found.
+func (db *DB) GroupVolumes() (int, error) {
+	counts, err := db.pageCounts()
+	if err != nil {
+		return 0, err
+	}
+
+	manual := make(map[string]bool)
+	rows, err := db.Query("SELECT path FROM volumes WHERE manual = 1")
+	if err != nil {
+		return 0, fmt.Errorf("querying manual volumes: %w", err)
+	}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning manual volume: %w", err)
+		}
+		manual[path] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("querying manual volumes: %w", err)
+	}
+
+	type volumeFile struct {
+		path   string
+		number int
+	}
+	groups := make(map[string][]volumeFile)
+	names := make(map[string]string)
+	for path := range counts {
+		if manual[path] {
+			continue
+		}
+		base := filepath.Base(path)
+		match := volumePattern.FindStringSubmatch(strings.TrimSuffix(base, filepath.Ext(base)))
+		if match == nil || match[1] == "" {
+			continue
+		}
+		number, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		key := filepath.Join(filepath.Dir(path), strings.ToLower(match[1]))
+		groups[key] = append(groups[key], volumeFile{path, number})
+		names[key] = match[1]
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction for volumes: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM volumes WHERE manual = 0"); err != nil {
+		return 0, fmt.Errorf("clearing automatic volumes: %w", err)
+	}
+
+	documents := 0
+	for key, files := range groups {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].number < files[j].number })
+
+		offset := 0
+		for _, file := range files {
+			if _, err := tx.Exec(
+				"INSERT INTO volumes (path, document, volume, page_offset) VALUES (?, ?, ?, ?)",
+				file.path, names[key], file.number, offset,
+			); err != nil {
+				return 0, fmt.Errorf("inserting volume %s: %w", file.path, err)
+			}
+			offset += counts[file.path]
+		}
+		documents++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing volumes: %w", err)
+	}
+	return documents, nil
+}
+
+// SetVolumes groups the given indexed files, in order, into a logical
+// document, overriding the automatic grouping
+func (db *DB) SetVolumes(document string, paths []string) error {
+	counts, err := db.pageCounts()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction for volumes: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM volumes WHERE document = ?", document); err != nil {
+		return fmt.Errorf("clearing volumes of %s: %w", document, err)
+	}
+
+	offset := 0
+	for i, path := range paths {
+		count, ok := counts[path]
+		if !ok {
+			return fmt.Errorf("file %s is not indexed", path)
+		}
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO volumes (path, document, volume, page_offset, manual) VALUES (?, ?, ?, ?, 1)",
+			path, document, i+1, offset,
+		); err != nil {
+			return fmt.Errorf("inserting volume %s: %w", path, err)
+		}
+		offset += count
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing volumes of %s: %w", document, err)
+	}
+	return nil
+}
+
+// RemoveVolumes ungroups the files of a logical document, returning the number
+// of files removed
+func (db *DB) RemoveVolumes(document string) (int, error) {
+	result, err := db.Exec("DELETE FROM volumes WHERE document = ?", document)
+	if err != nil {
+		return 0, fmt.Errorf("removing volumes of %s: %w", document, err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("removing volumes of %s: %w", document, err)
+	}
+	return int(removed), nil
+}
+
+// Volumes returns the volume of every grouped file, keyed by path
+func (db *DB) Volumes() (map[string]Volume, error) {
+	rows, err := db.Query("SELECT path, document, volume, page_offset FROM volumes")
+	if err != nil {
+		return nil, fmt.Errorf("querying volumes: %w", err)
+	}
+	defer rows.Close()
+
+	volumes := make(map[string]Volume)
+	for rows.Next() {
+		var path string
+		var volume Volume
+		if err := rows.Scan(&path, &volume.Document, &volume.Number, &volume.PageOffset); err != nil {
+			return nil, fmt.Errorf("scanning volume: %w", err)
+		}
+		volumes[path] = volume
+	}
+	return volumes, rows.Err()
+}
@@ -0,0 +1,46 @@
+package database
+
+import "testing"
+
+func TestVolumePattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		volume   string
+	}{
+		{"Book vol2", "Book", "2"},
+		{"Book vol1", "Book", "1"},
+		{"book_volume_02", "book", "02"},
+		{"book-part3", "book", "3"},
+		{"book vol. 1", "book", "1"},
+		{"Collected Works Volume 10", "Collected Works", "10"},
+		{"BOOK PART 4", "BOOK", "4"},
+
+		// No keyword, or no separator before it
+		{"book v1", "", ""},
+		{"book pt2", "", ""},
+		{"report2", "", ""},
+		{"impart3", "", ""},
+		{"Evolve2", "", ""},
+		{"vol2", "", ""},
+		{"book vol", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := volumePattern.FindStringSubmatch(tt.name)
+			if tt.document == "" {
+				if match != nil {
+					t.Fatalf("expected no match, got document %q volume %q", match[1], match[2])
+				}
+				return
+			}
+			if match == nil {
+				t.Fatalf("expected document %q volume %q, got no match", tt.document, tt.volume)
+			}
+			if match[1] != tt.document || match[2] != tt.volume {
+				t.Errorf("got document %q volume %q, want %q %q", match[1], match[2], tt.document, tt.volume)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package lockfile
+
+import "errors"
+
+// ErrLocked is returned by TryAcquire when the lock is held by another process
+var ErrLocked = errors.New("lock is held by another process")
+
+// Lock is an advisory lock on a file, used to keep processes from running the
+// same operation concurrently
+type Lock struct {
+	lock platformLock
+}
+
+// TryAcquire acquires the lock on the file at path without waiting, returning
+// ErrLocked if another process holds it. The file is created if needed.
+func TryAcquire(path string) (*Lock, error) {
+	l, err := tryLock(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{lock: l}, nil
+}
+
+// Acquire acquires the lock on the file at path, waiting until it is released
+// by any other process holding it
+func Acquire(path string) (*Lock, error) {
+	l, err := waitLock(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{lock: l}, nil
+}
+
+// Release releases the lock, a nil lock is released without doing anything
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	return l.lock.unlock()
+}
@@ -0,0 +1,45 @@
+//go:build !unix
+
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// pollInterval is how often waitLock retries to create the lock file
+const pollInterval = 500 * time.Millisecond
+
+// platformLock holds the path of the lock file, whose existence is the lock.
+// A lock file left behind by a crashed process must be removed by hand.
+type platformLock struct {
+	path string
+}
+
+func tryLock(path string) (platformLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return platformLock{}, ErrLocked
+		}
+		return platformLock{}, fmt.Errorf("creating lock file %s: %w", path, err)
+	}
+	file.Close()
+	return platformLock{path: path}, nil
+}
+
+func waitLock(path string) (platformLock, error) {
+	for {
+		l, err := tryLock(path)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func (l platformLock) unlock() error {
+	return os.Remove(l.path)
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// platformLock holds the locked file, the lock is released by the kernel if
+// the process exits without releasing it
+type platformLock struct {
+	file *os.File
+}
+
+func lockFile(path string, how int) (platformLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return platformLock{}, fmt.Errorf("opening lock file %s: %w", path, err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), how); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return platformLock{}, ErrLocked
+		}
+		return platformLock{}, fmt.Errorf("locking %s: %w", path, err)
+	}
+
+	return platformLock{file: file}, nil
+}
+
+func tryLock(path string) (platformLock, error) {
+	return lockFile(path, syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func waitLock(path string) (platformLock, error) {
+	return lockFile(path, syscall.LOCK_EX)
+}
+
+func (l platformLock) unlock() error {
+	// The file is kept on disk, removing it could let another process lock a
+	// file that is about to be unlinked
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	return l.file.Close()
+}
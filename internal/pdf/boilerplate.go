@@ -0,0 +1,76 @@
+package pdf
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+var digitsNormalizer = regexp.MustCompile(`\d+`)
+
+// minBoilerplatePages is the minimum number of pages a document needs for
+// repeated lines to be considered boilerplate
+const minBoilerplatePages = 3
+
+// boilerplateKey normalizes a line for comparison across pages, so running
+// headers like "Page 3 of 10" and "Page 4 of 10" are considered equal
+func boilerplateKey(line string) string {
+	return digitsNormalizer.ReplaceAllString(strings.ToLower(line), "#")
+}
+
+// StripBoilerplate removes running headers and footers, that is lines appearing
+// on at least the given fraction of the pages of a document. Pages are
+// compared on their line-preserving text, the collapsed content is rebuilt
+// from the remaining lines.
+func (e *Extractor) StripBoilerplate(pages []Page, threshold float64) []Page {
+	if len(pages) < minBoilerplatePages {
+		return pages
+	}
+
+	// Count on how many pages each normalized line appears
+	pageCounts := make(map[string]int)
+	for _, page := range pages {
+		seen := make(map[string]bool)
+		for _, line := range strings.Split(page.Original, "\n") {
+			key := boilerplateKey(line)
+			if key != "" && !seen[key] {
+				seen[key] = true
+				pageCounts[key]++
+			}
+		}
+	}
+
+	minCount := max(minBoilerplatePages, int(threshold*float64(len(pages))+0.5))
+	boilerplate := make(map[string]bool)
+	for key, count := range pageCounts {
+		if count >= minCount {
+			boilerplate[key] = true
+			if e.verbose {
+				log.Printf("Removing boilerplate line %q found on %d of %d pages", key, count, len(pages))
+			}
+		}
+	}
+	if len(boilerplate) == 0 {
+		return pages
+	}
+
+	stripped := make([]Page, len(pages))
+	for i, page := range pages {
+		var lines []string
+		for _, line := range strings.Split(page.Original, "\n") {
+			if !boilerplate[boilerplateKey(line)] {
+				lines = append(lines, line)
+			}
+		}
+		// Only the text changes, the annotations are added back like in PageFromRaw
+		stripped[i] = page
+		stripped[i].Content = strings.Join(lines, " ")
+		stripped[i].Original = strings.Join(lines, "\n")
+		stripped[i].Quality = TextQuality(stripped[i].Content)
+		if page.Annotations != "" {
+			stripped[i].Content = strings.TrimSpace(stripped[i].Content + " " + page.Annotations)
+		}
+	}
+
+	return stripped
+}
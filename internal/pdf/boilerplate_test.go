@@ -0,0 +1,111 @@
+package pdf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStripBoilerplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     []Page
+		threshold float64
+		want      []string // Original of each page after stripping
+	}{
+		{
+			name: "running header and numbered footer",
+			pages: []Page{
+				{Original: "Annual Report\nfirst page text\nPage 1 of 3"},
+				{Original: "Annual Report\nsecond page text\nPage 2 of 3"},
+				{Original: "Annual Report\nthird page text\nPage 3 of 3"},
+			},
+			threshold: 0.5,
+			want:      []string{"first page text", "second page text", "third page text"},
+		},
+		{
+			name: "line below the threshold is kept",
+			pages: []Page{
+				{Original: "Chapter One\nalpha"},
+				{Original: "Chapter One\nbeta"},
+				{Original: "gamma"},
+				{Original: "delta"},
+				{Original: "epsilon"},
+				{Original: "zeta"},
+			},
+			threshold: 0.5,
+			want:      []string{"Chapter One\nalpha", "Chapter One\nbeta", "gamma", "delta", "epsilon", "zeta"},
+		},
+		{
+			name: "too few pages",
+			pages: []Page{
+				{Original: "Header\none"},
+				{Original: "Header\ntwo"},
+			},
+			threshold: 0.5,
+			want:      []string{"Header\none", "Header\ntwo"},
+		},
+	}
+
+	e := New(false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped := e.StripBoilerplate(tt.pages, tt.threshold)
+			var got []string
+			for _, page := range stripped {
+				got = append(got, page.Original)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripBoilerplateKeepsPageFields(t *testing.T) {
+	pages := []Page{
+		{Original: "Header\none", Raw: "Header\none raw", PageNum: 4, Annotations: "https://example.com/a"},
+		{Original: "Header\ntwo", Raw: "Header\ntwo raw", PageNum: 4},
+		{Original: "Header\nthree", Raw: "Header\nthree raw", PageNum: 5, Annotations: "https://example.com/b"},
+	}
+
+	stripped := New(false).StripBoilerplate(pages, 0.5)
+
+	tests := []struct {
+		content     string
+		raw         string
+		pageNum     int
+		annotations string
+	}{
+		{"one https://example.com/a", "Header\none raw", 4, "https://example.com/a"},
+		{"two", "Header\ntwo raw", 4, ""},
+		{"three https://example.com/b", "Header\nthree raw", 5, "https://example.com/b"},
+	}
+	for i, tt := range tests {
+		page := stripped[i]
+		if page.Content != tt.content || page.Raw != tt.raw || page.PageNum != tt.pageNum || page.Annotations != tt.annotations {
+			t.Errorf("page %d: got content %q raw %q page %d annotations %q, want %q %q %d %q",
+				i, page.Content, page.Raw, page.PageNum, page.Annotations, tt.content, tt.raw, tt.pageNum, tt.annotations)
+		}
+		if page.Quality != TextQuality(page.Original) {
+			t.Errorf("page %d: quality %v not computed on the stripped text", i, page.Quality)
+		}
+	}
+}
+
+func TestBoilerplateKey(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"Page 3 of 10", "page # of #"},
+		{"Page 14 of 10", "page # of #"},
+		{"ANNUAL REPORT 2023", "annual report #"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := boilerplateKey(tt.line); got != tt.want {
+			t.Errorf("boilerplateKey(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
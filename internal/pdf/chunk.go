@@ -0,0 +1,155 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk modes, the unit stored as a row of the index
+const (
+	ChunkPage      = "page"
+	ChunkParagraph = "paragraph"
+)
+
+const (
+	// minParagraphChars merges shorter paragraphs (headings, captions, short
+	// lines) with the following one, so each chunk has enough text to rank
+	minParagraphChars = 300
+	// maxParagraphChars splits longer paragraphs at sentence boundaries
+	maxParagraphChars = 1500
+)
+
+// SplitParagraphs splits every page into paragraph chunks, each returned as a
+// Page with PageNum set to the number of the page it comes from. Paragraphs
+// end at blank lines or at lines ending a sentence, short ones are merged with
+// the next and long ones are cut at sentence boundaries.
+func SplitParagraphs(pages []Page) []Page {
+	var chunks []Page
+	for i, page := range pages {
+		pageNum := page.PageNum
+		if pageNum == 0 {
+			pageNum = i + 1
+		}
+
+		text := page.Original
+		if text == "" {
+			text = page.Content
+		}
+		paragraphs := mergeShortParagraphs(splitParagraphText(text))
+		if len(paragraphs) <= 1 {
+			page.PageNum = pageNum
+			chunks = append(chunks, page)
+			continue
+		}
+
+		for _, paragraph := range paragraphs {
+			content := strings.Join(strings.Fields(paragraph), " ")
+			chunks = append(chunks, Page{
+				Content:  content,
+				Original: paragraph,
+				PageNum:  pageNum,
+				Quality:  TextQuality(content),
+			})
+		}
+	}
+	return chunks
+}
+
+// splitParagraphText cuts the lines of a page into paragraphs
+func splitParagraphText(text string) []string {
+	var paragraphs []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			paragraphs = append(paragraphs, splitSentences(strings.Join(current, "\n"), maxParagraphChars)...)
+			current = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+		if endsSentence(line) {
+			flush()
+		}
+	}
+	flush()
+	return paragraphs
+}
+
+// endsSentence reports whether a line ends with sentence punctuation,
+// optionally followed by a closing quote or parenthesis
+func endsSentence(line string) bool {
+	line = strings.TrimRight(line, `"')]»”’`)
+	return strings.HasSuffix(line, ".") || strings.HasSuffix(line, "!") ||
+		strings.HasSuffix(line, "?") || strings.HasSuffix(line, ":")
+}
+
+// mergeShortParagraphs joins each paragraph shorter than minParagraphChars
+// with the following ones
+func mergeShortParagraphs(paragraphs []string) []string {
+	var merged []string
+	var current string
+	for _, paragraph := range paragraphs {
+		if current == "" {
+			current = paragraph
+		} else {
+			current += "\n" + paragraph
+		}
+		if utf8.RuneCountInString(current) >= minParagraphChars {
+			merged = append(merged, current)
+			current = ""
+		}
+	}
+	if current != "" {
+		// A short trailing paragraph joins the previous one
+		if len(merged) > 0 {
+			merged[len(merged)-1] += "\n" + current
+		} else {
+			merged = append(merged, current)
+		}
+	}
+	return merged
+}
+
+// splitSentences cuts text into chunks of at most maxChars runes after the
+// last sentence end that fits, or like splitText for a sentence longer than that
+func splitSentences(text string, maxChars int) []string {
+	var chunks []string
+	for utf8.RuneCountInString(text) > maxChars {
+		// Byte offset of the first rune past the budget
+		limit := len(text)
+		count := 0
+		for offset := range text {
+			if count == maxChars {
+				limit = offset
+				break
+			}
+			count++
+		}
+
+		cut := -1
+		for _, end := range []string{". ", "! ", "? ", ".\n", "!\n", "?\n"} {
+			if i := strings.LastIndex(text[:limit], end); i >= 0 && i+1 > cut {
+				cut = i + 1
+			}
+		}
+		if cut <= 0 {
+			cut = strings.LastIndexAny(text[:limit], " \n")
+		}
+		if cut <= 0 {
+			cut = limit
+		}
+
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
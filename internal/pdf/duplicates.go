@@ -0,0 +1,68 @@
+package pdf
+
+import "strings"
+
+// duplicateKey normalizes the content of a page for comparison, ignoring case
+// and whitespace
+func duplicateKey(page Page) string {
+	return strings.ToLower(strings.Join(strings.Fields(page.Content), " "))
+}
+
+// DuplicatePageRatio returns the fraction of pages whose content is identical
+// to an earlier page of the same document, blank pages included
+func DuplicatePageRatio(pages []Page) float64 {
+	if len(pages) == 0 {
+		return 0
+	}
+
+	seen := make(map[string]bool)
+	duplicates := 0
+	for _, page := range pages {
+		key := duplicateKey(page)
+		if seen[key] {
+			duplicates++
+		}
+		seen[key] = true
+	}
+	return float64(duplicates) / float64(len(pages))
+}
+
+// CollapseDuplicatePages keeps only the first page of each distinct content,
+// with PageNum set to its number in the document
+func CollapseDuplicatePages(pages []Page) []Page {
+	seen := make(map[string]bool)
+	var unique []Page
+	for i, page := range pages {
+		key := duplicateKey(page)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if page.PageNum == 0 {
+			page.PageNum = i + 1
+		}
+		unique = append(unique, page)
+	}
+	return unique
+}
+
+// DropEmptyPages removes the pages without any text, with PageNum set to its
+// number in the document on the others. A document without any text keeps its
+// first page, so it stays indexed and isn't extracted again by every scan.
+func DropEmptyPages(pages []Page) []Page {
+	var kept []Page
+	for i, page := range pages {
+		if strings.TrimSpace(page.Content) == "" {
+			continue
+		}
+		if page.PageNum == 0 {
+			page.PageNum = i + 1
+		}
+		kept = append(kept, page)
+	}
+	if len(kept) == 0 && len(pages) > 0 {
+		return pages[:1]
+	}
+	return kept
+}
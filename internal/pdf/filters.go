@@ -0,0 +1,120 @@
+package pdf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/transform"
+)
+
+// TextFilter is a named step of the text cleaning pipeline. Filters receive
+// the text of a page with its line breaks, CleanText collapses the remaining
+// whitespace after all of them ran.
+type TextFilter struct {
+	Name  string
+	Apply func(text string) string
+}
+
+// Built-in filters
+var (
+	// DiacriticsFilter removes accents and replaces em dashes with hyphens
+	DiacriticsFilter = TextFilter{Name: "diacritics", Apply: removeDiacriticsText}
+	// WhitespaceFilter collapses whitespace within each line and drops blank lines
+	WhitespaceFilter = TextFilter{Name: "whitespace", Apply: collapseLineSpaces}
+	// DehyphenateFilter joins words hyphenated across a line break
+	DehyphenateFilter = TextFilter{Name: "dehyphenate", Apply: dehyphenate}
+)
+
+// DefaultFilterNames are the filters applied by a new Extractor, in order
+var DefaultFilterNames = []string{DiacriticsFilter.Name, WhitespaceFilter.Name}
+
+var registeredFilters = make(map[string]TextFilter)
+
+func init() {
+	for _, filter := range []TextFilter{DiacriticsFilter, WhitespaceFilter, DehyphenateFilter} {
+		RegisterFilter(filter)
+	}
+}
+
+// RegisterFilter makes a filter available by name to LookupFilters, replacing
+// a filter registered with the same name
+func RegisterFilter(filter TextFilter) {
+	registeredFilters[filter.Name] = filter
+}
+
+// FilterNames returns the names of the registered filters, sorted
+func FilterNames() []string {
+	names := make([]string, 0, len(registeredFilters))
+	for name := range registeredFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupFilters returns the registered filters with the given names, in order
+func LookupFilters(names []string) ([]TextFilter, error) {
+	filters := make([]TextFilter, 0, len(names))
+	for _, name := range names {
+		filter, ok := registeredFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown text filter %q, expected one of: %s", name, strings.Join(FilterNames(), ", "))
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// applyFilters runs the extractor's filters over the text in order
+func (e *Extractor) applyFilters(text string) string {
+	// Broken fonts or encodings can yield invalid UTF-8, which would end up in
+	// snippets and break width calculations when rendering, drop those bytes
+	text = strings.ToValidUTF8(text, "")
+
+	for _, filter := range e.Filters {
+		text = filter.Apply(text)
+	}
+	return text
+}
+
+func removeDiacriticsText(s string) string {
+	result, _, err := transform.String(removeDiacritics(), s)
+	if err != nil {
+		panic(fmt.Sprintf("normalizing string failed: %v", err))
+	}
+
+	return result
+}
+
+// NormalizeQuery removes the accents of a query like DiacriticsFilter does
+// for the indexed text, so that accented queries match. Em dashes are kept,
+// a hyphen isn't valid in a bare FTS term.
+func NormalizeQuery(query string) string {
+	result, _, err := transform.String(foldAccents(), query)
+	if err != nil {
+		return query
+	}
+	return result
+}
+
+func collapseLineSpaces(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(spaceNormalizer.ReplaceAllString(line, " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// hyphenatedBreak matches a letter followed by a hyphen at the end of a line
+// and a lowercase letter starting the next one
+var hyphenatedBreak = regexp.MustCompile(`(\p{L})-[ \t]*\n\s*(\p{Ll})`)
+
+func dehyphenate(text string) string {
+	return hyphenatedBreak.ReplaceAllString(text, "$1$2")
+}
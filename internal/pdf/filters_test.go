@@ -0,0 +1,94 @@
+package pdf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTextFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []string
+		text    string
+		want    string // CleanLines result
+	}{
+		{"default", DefaultFilterNames, "  Café  au\tlait \n\n— fin ", "Cafe au lait\n- fin"},
+		{"no filters", nil, "Café\n\nfin", "Café\n\nfin"},
+		{"dehyphenate", []string{"dehyphenate"}, "hyphen-\nated and Self-\nService", "hyphenated and Self-\nService"},
+		{"dehyphenate after whitespace", []string{"whitespace", "dehyphenate"}, "inter-  \n   national", "international"},
+		{"order matters", []string{"dehyphenate", "whitespace"}, "inter-  \n   national", "international"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := LookupFilters(tt.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := New(false)
+			e.Filters = filters
+			if got := e.CleanLines(tt.text); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupFilters(t *testing.T) {
+	RegisterFilter(TextFilter{Name: "upper", Apply: strings.ToUpper})
+	defer delete(registeredFilters, "upper")
+
+	tests := []struct {
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{names: []string{"whitespace", "diacritics"}, want: []string{"whitespace", "diacritics"}},
+		{names: []string{"upper"}, want: []string{"upper"}},
+		{names: []string{"whitespace", "unknown"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.names, ","), func(t *testing.T) {
+			filters, err := LookupFilters(tt.names)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, filter := range filters {
+				got = append(got, filter.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"café", "cafe"},
+		{"Naïve Ångström", "Naive Angstrom"},
+		{"a — b", "a — b"},
+		{`"crème brûlée" OR résumé*`, `"creme brulee" OR resume*`},
+		{"plain query", "plain query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := NormalizeQuery(tt.query); got != tt.want {
+				t.Errorf("NormalizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// ErrMuPDFUnavailable is returned when the MuPDF library used for extraction
+// can't be initialized, which is a problem of the build or the environment
+// rather than of a particular file
+var ErrMuPDFUnavailable = errors.New("MuPDF is not available")
+
+// mupdfHelp explains how to get a working MuPDF, shown with ErrMuPDFUnavailable
+const mupdfHelp = "pdf-fts needs MuPDF to extract text: build it with cgo enabled " +
+	"(CGO_ENABLED=1 and a C compiler) to link the bundled library, or for builds " +
+	"with the nocgo tag install libmupdf and set FZ_VERSION to its exact version"
+
+var (
+	mupdfOnce sync.Once
+	mupdfErr  error
+)
+
+// CheckMuPDF opens a small generated PDF once to make sure MuPDF works,
+// returning an error wrapping ErrMuPDFUnavailable with build and runtime
+// requirements if it doesn't. Later calls return the first result.
+func CheckMuPDF() error {
+	mupdfOnce.Do(func() {
+		mupdfErr = openSample()
+	})
+	return mupdfErr
+}
+
+// openSample opens and reads a generated PDF, turning panics from the
+// bindings into errors
+func openSample() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v\n%s", ErrMuPDFUnavailable, r, mupdfHelp)
+		}
+	}()
+
+	doc, err := fitz.NewFromMemory(SamplePDF("pdf-fts"))
+	if err != nil {
+		return mupdfError(err)
+	}
+	defer doc.Close()
+
+	if _, err := doc.Text(0); err != nil {
+		return fmt.Errorf("%w: reading a generated PDF: %v\n%s", ErrMuPDFUnavailable, err, mupdfHelp)
+	}
+	return nil
+}
+
+// mupdfError wraps the errors of go-fitz meaning that MuPDF itself couldn't
+// start, leaving errors about a specific document unchanged
+func mupdfError(err error) error {
+	if errors.Is(err, fitz.ErrCreateContext) {
+		return fmt.Errorf("%w: %v\n%s", ErrMuPDFUnavailable, err, mupdfHelp)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package pdf
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// Section is an entry of the outline (table of contents) of a document with
+// the range of pages it spans
+type Section struct {
+	Level int // depth in the outline, starting at 1
+	Title string
+	// FromPage and ToPage are the first and last page of the section. A
+	// section ends on the page before the next section at the same or an
+	// upper level, or on the last page of the document.
+	FromPage int
+	ToPage   int
+}
+
+// ExtractOutline returns the sections of the outline of a PDF in document
+// order, or none if it has no outline. Entries linking outside the document
+// are skipped.
+func (e *Extractor) ExtractOutline(pdfPath string) ([]Section, error) {
+	doc, err := e.openPDFReader(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	numPages, err := e.pageCount(doc, pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	outline, err := doc.ToC()
+	if errors.Is(err, fitz.ErrLoadOutline) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, mupdfError(err)
+	}
+
+	var sections []Section
+	for _, entry := range outline {
+		title := strings.Join(strings.Fields(entry.Title), " ")
+		if entry.Page < 0 || entry.Page >= numPages || title == "" {
+			continue
+		}
+		sections = append(sections, Section{
+			Level:    entry.Level,
+			Title:    title,
+			FromPage: entry.Page + 1, // go-fitz pages start at 0
+			ToPage:   numPages,
+		})
+	}
+
+	for i := range sections {
+		for _, next := range sections[i+1:] {
+			if next.Level <= sections[i].Level {
+				sections[i].ToPage = max(sections[i].FromPage, next.FromPage-1)
+				break
+			}
+		}
+	}
+	return sections, nil
+}
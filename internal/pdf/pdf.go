@@ -3,11 +3,13 @@ package pdf
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/gen2brain/go-fitz"
@@ -20,15 +22,54 @@ var (
 	spaceNormalizer = regexp.MustCompile(`\s+`)
 )
 
+var (
+	// ErrExtractTimeout is returned when extracting a file takes longer than the allowed timeout
+	ErrExtractTimeout = errors.New("extraction timed out")
+	// ErrNoPages is returned when a document opens but reports no pages, which
+	// means its structure couldn't be read rather than it being empty
+	ErrNoPages = errors.New("document reports no pages")
+	// ErrTooMuchText is returned when the text extracted from a document
+	// exceeds the extractor's MaxExtractedBytes
+	ErrTooMuchText = errors.New("extracted text exceeds the size limit")
+)
+
+// PageBreak is a page separator that survives text cleaning, a form feed
+// followed by a newline, for output that must be split back into pages
+const PageBreak = "\f\n"
+
 // Extractor handles PDF text extraction operations
 type Extractor struct {
 	verbose bool
+
+	// PageSeparator is inserted between pages by ExtractText and ExtractAllText,
+	// a newline by default. Use PageBreak to keep the page boundaries.
+	PageSeparator string
+
+	// IndexAnnotations adds the annotations of each page to its indexed
+	// content. go-fitz only exposes link annotations, so for now these are the
+	// link targets; comments and form fields are not available.
+	IndexAnnotations bool
+
+	// Filters are the cleaning steps applied by CleanText and CleanLines, in
+	// order, the DefaultFilterNames by default
+	Filters []TextFilter
+
+	// MaxExtractedBytes stops the extraction of a document whose raw text
+	// grows past this many bytes with ErrTooMuchText, so a single huge
+	// document can't exhaust the memory. Zero disables the limit.
+	MaxExtractedBytes int64
 }
 
 // New creates a new PDF extractor
 func New(verbose bool) *Extractor {
+	filters, err := LookupFilters(DefaultFilterNames)
+	if err != nil {
+		panic(err)
+	}
 	return &Extractor{
-		verbose: verbose,
+		verbose:       verbose,
+		PageSeparator: "\n",
+		Filters:       filters,
 	}
 }
 
@@ -52,12 +93,22 @@ func (e *Extractor) HashFile(filePath string) (string, error) {
 func (e *Extractor) openPDFReader(pdfPath string) (*fitz.Document, error) {
 	doc, err := fitz.New(pdfPath)
 	if err != nil {
-		return nil, fmt.Errorf("opening PDF file %s: %w", pdfPath, err)
+		return nil, fmt.Errorf("opening PDF file %s: %w", pdfPath, mupdfError(err))
 	}
 
 	return doc, nil
 }
 
+// pageCount returns the number of pages of a document, treating a zero or
+// negative count as a failure to read the document structure
+func (e *Extractor) pageCount(doc *fitz.Document, pdfPath string) (int, error) {
+	numPages := doc.NumPage()
+	if numPages <= 0 {
+		return 0, fmt.Errorf("reading %s (page count %d): %w", pdfPath, numPages, ErrNoPages)
+	}
+	return numPages, nil
+}
+
 // logWarning logs a warning message if verbose mode is enabled
 func (e *Extractor) logWarning(format string, args ...interface{}) {
 	if e.verbose {
@@ -75,56 +126,113 @@ func (e *Extractor) extractPageText(doc *fitz.Document, pageNum int, pdfPath str
 	return text, nil
 }
 
-// ExtractText extracts text content from a PDF file using github.com/gen2brain/go-fitz
+// pageAnnotations returns the text of the annotations of a page that go-fitz
+// exposes, that is the targets of external link annotations
+func (e *Extractor) pageAnnotations(doc *fitz.Document, pageIndex int, pdfPath string) string {
+	links, err := doc.Links(pageIndex)
+	if err != nil {
+		e.logWarning("could not read annotations of page %d of %s: %v", pageIndex+1, pdfPath, err)
+		return ""
+	}
+
+	var uris []string
+	for _, link := range links {
+		// Internal links point to named destinations like "#nameddest=..."
+		if link.URI != "" && !strings.HasPrefix(link.URI, "#") {
+			uris = append(uris, link.URI)
+		}
+	}
+	return e.CleanText(strings.Join(uris, " "))
+}
+
+// maxPageRetries bounds how many times a failed page is retried after
+// reopening the document
+const maxPageRetries = 1
+
+// pageTextWithRetry extracts the text of a page, retrying with a freshly opened
+// document when it fails since some failures (e.g. with certain fonts) are
+// transient and don't happen again on a new document
+func (e *Extractor) pageTextWithRetry(doc *fitz.Document, pageIndex int, pdfPath string) (string, error) {
+	text, err := e.extractPageText(doc, pageIndex, pdfPath)
+	for attempt := 1; err != nil && attempt <= maxPageRetries; attempt++ {
+		e.logWarning("could not extract text from page %d of %s, retrying after reopening (attempt %d): %v",
+			pageIndex+1, pdfPath, attempt, err)
+
+		retryDoc, openErr := e.openPDFReader(pdfPath)
+		if openErr != nil {
+			return "", openErr
+		}
+		text, err = e.extractPageText(retryDoc, pageIndex, pdfPath)
+		retryDoc.Close()
+	}
+	return text, err
+}
+
+// ExtractText extracts text content from a PDF file using github.com/gen2brain/go-fitz,
+// joining the pages with the extractor's PageSeparator
 func (e *Extractor) ExtractText(pdfPath string) (string, error) {
-	doc, err := e.openPDFReader(pdfPath)
+	pages, err := e.extractRawPages(pdfPath)
 	if err != nil {
 		return "", err
 	}
+	return strings.Join(pages, e.PageSeparator), nil
+}
+
+// extractRawPages extracts the uncleaned text of each page, leaving pages that
+// fail empty so the page numbering is kept
+func (e *Extractor) extractRawPages(pdfPath string) ([]string, error) {
+	doc, err := e.openPDFReader(pdfPath)
+	if err != nil {
+		return nil, err
+	}
 	defer doc.Close()
 
-	numPages := doc.NumPage()
+	numPages, err := e.pageCount(doc, pdfPath)
+	if err != nil {
+		return nil, err
+	}
 
-	var allText strings.Builder
+	pages := make([]string, 0, numPages)
 	for pageIndex := 0; pageIndex < numPages; pageIndex++ {
-		text, err := e.extractPageText(doc, pageIndex, pdfPath)
+		text, err := e.pageTextWithRetry(doc, pageIndex, pdfPath)
 		if err != nil {
 			// Log error but continue to extract from other pages if possible
 			e.logWarning("could not extract text from page %d of %s: %v", pageIndex+1, pdfPath, err)
-			continue
+			text = ""
 		}
-		allText.WriteString(text)
-		allText.WriteString("\n") // Add a newline between pages
+		pages = append(pages, text)
 	}
 
-	return allText.String(), nil
+	return pages, nil
 }
 
-var removeDiacritics = transform.Chain(
-	norm.NFD,
-	runes.Remove(runes.In(unicode.Mn)),
-	runes.Map(func(r rune) rune {
-		if r == '\u2014' { // Em dash (—)
-			return '-'
-		}
-		return r
-	}),
-	norm.NFC,
-)
-
-func normalizeUnicode(s string) string {
-	result, _, err := transform.String(removeDiacritics, s)
-	if err != nil {
-		panic(fmt.Sprintf("normalizing string failed: %v", err))
-	}
+// foldAccents returns a transformer removing the combining marks left by
+// decomposing accented letters. Chained transformers keep state, so each
+// goroutine needs its own.
+func foldAccents() transform.Transformer {
+	return transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+}
 
-	return result
+// removeDiacritics returns a transformer like foldAccents also replacing em
+// dashes with hyphens
+func removeDiacritics() transform.Transformer {
+	return transform.Chain(
+		norm.NFD,
+		runes.Remove(runes.In(unicode.Mn)),
+		runes.Map(func(r rune) rune {
+			if r == '\u2014' { // Em dash (—)
+				return '-'
+			}
+			return r
+		}),
+		norm.NFC,
+	)
 }
 
-// CleanText normalizes and cleans extracted text
+// CleanText normalizes and cleans extracted text with the extractor's filters,
+// collapsing all whitespace to single spaces
 func (e *Extractor) CleanText(text string) string {
-	// Normalize Unicode
-	text = normalizeUnicode(text)
+	text = e.applyFilters(text)
 
 	// Replace multiple whitespace with single space
 	text = spaceNormalizer.ReplaceAllString(text, " ")
@@ -135,39 +243,150 @@ func (e *Extractor) CleanText(text string) string {
 	return text
 }
 
-// ExtractAllText extracts and cleans text from a PDF file
+// ExtractAllText extracts and cleans text from a PDF file. The default newline
+// page separator is collapsed like any other whitespace, others (e.g.
+// PageBreak) are kept between the cleaned pages.
 func (e *Extractor) ExtractAllText(filePath string) (string, error) {
 	// Extract text
-	rawText, err := e.ExtractText(filePath)
+	pages, err := e.extractRawPages(filePath)
 	if err != nil {
 		return "", fmt.Errorf("extracting text from %s: %w", filePath, err)
 	}
 
-	// Clean text
-	cleanedText := e.CleanText(rawText)
+	if e.PageSeparator == "\n" {
+		return e.CleanText(strings.Join(pages, e.PageSeparator)), nil
+	}
 
-	return cleanedText, nil
+	// Clean text page by page, cleaning would collapse the separator
+	for i, page := range pages {
+		pages[i] = e.CleanText(page)
+	}
+	return strings.Join(pages, e.PageSeparator), nil
 }
 
-// ExtractPagesText extracts text from each page of a PDF and returns a list of cleaned strings.
-func (e *Extractor) ExtractPagesText(pdfPath string) ([]string, error) {
+// CleanLines normalizes text like CleanText but preserves line breaks, with
+// the default filters whitespace is only collapsed within each line and blank
+// lines are dropped
+func (e *Extractor) CleanLines(text string) string {
+	return e.applyFilters(text)
+}
+
+// Page holds the extracted text of a single PDF page
+type Page struct {
+	// Content is the cleaned text with all whitespace collapsed, used for indexing
+	Content string
+	// Original is the cleaned text with the original line breaks preserved
+	Original string
+	// Raw is the text as extracted, before any cleaning
+	Raw string
+	// Annotations is the text of the page annotations, included in Content
+	// when the extractor has IndexAnnotations set
+	Annotations string
+	// PageNum is the number of the page in the document, set by SplitLongPages.
+	// Zero means the page number is its position in the document.
+	PageNum int
+	// Quality is the TextQuality of the extracted text, without annotations
+	Quality float64
+}
+
+// ExtractPages extracts text from each page of a PDF, keeping both the
+// collapsed and the line-preserving form of the cleaned text.
+func (e *Extractor) ExtractPages(pdfPath string) ([]Page, error) {
 	doc, err := e.openPDFReader(pdfPath)
 	if err != nil {
 		return nil, err
 	}
 	defer doc.Close()
 
-	numPages := doc.NumPage()
+	numPages, err := e.pageCount(doc, pdfPath)
+	if err != nil {
+		return nil, err
+	}
 
-	var pagesText []string
+	var pages []Page
+	var extracted int64
 	for pageIndex := 0; pageIndex < numPages; pageIndex++ {
-		text, err := e.extractPageText(doc, pageIndex, pdfPath)
+		text, err := e.pageTextWithRetry(doc, pageIndex, pdfPath)
 		if err != nil {
 			e.logWarning("could not extract text from page %d of %s: %v", pageIndex+1, pdfPath, err)
-			pagesText = append(pagesText, "") // Add empty string for this page
+			pages = append(pages, Page{}) // Add empty page to keep numbering
 			continue
 		}
-		pagesText = append(pagesText, e.CleanText(text))
+		extracted += int64(len(text))
+		if e.MaxExtractedBytes > 0 && extracted > e.MaxExtractedBytes {
+			return nil, fmt.Errorf("extracting %s, stopped at page %d of %d: %w", pdfPath, pageIndex+1, numPages, ErrTooMuchText)
+		}
+		var annotations string
+		if e.IndexAnnotations {
+			annotations = e.pageAnnotations(doc, pageIndex, pdfPath)
+		}
+		pages = append(pages, e.PageFromRaw(text, annotations))
+	}
+
+	return pages, nil
+}
+
+// PageFromRaw cleans the raw text extracted from a page, adding the annotations
+// text to the indexed content. It is also used to reprocess stored raw text.
+func (e *Extractor) PageFromRaw(text, annotations string) Page {
+	page := Page{
+		Content:     e.CleanText(text),
+		Original:    e.CleanLines(text),
+		Raw:         text,
+		Annotations: annotations,
+	}
+	page.Quality = TextQuality(page.Content)
+	if annotations != "" {
+		page.Content = strings.TrimSpace(page.Content + " " + annotations)
+	}
+	return page
+}
+
+// ExtractPagesTimeout is like ExtractPages but gives up after the given timeout,
+// returning ErrExtractTimeout. A timeout of zero or less disables the limit.
+//
+// go-fitz calls into MuPDF through cgo and cannot be interrupted, so on timeout
+// the extraction goroutine is abandoned and keeps running in the background until
+// the underlying call returns; its result is then discarded. This only prevents
+// the caller from blocking, it does not reclaim the CPU or memory in use.
+func (e *Extractor) ExtractPagesTimeout(pdfPath string, timeout time.Duration) ([]Page, error) {
+	if timeout <= 0 {
+		return e.ExtractPages(pdfPath)
+	}
+
+	type extractResult struct {
+		pages []Page
+		err   error
+	}
+
+	// Buffered so the abandoned goroutine can always deliver its result and exit
+	done := make(chan extractResult, 1)
+	go func() {
+		pages, err := e.ExtractPages(pdfPath)
+		done <- extractResult{pages, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		return res.pages, res.err
+	case <-timer.C:
+		return nil, fmt.Errorf("extracting %s after %s: %w", pdfPath, timeout, ErrExtractTimeout)
+	}
+}
+
+// ExtractPagesText extracts text from each page of a PDF and returns a list of cleaned strings.
+func (e *Extractor) ExtractPagesText(pdfPath string) ([]string, error) {
+	pages, err := e.ExtractPages(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pagesText := make([]string, len(pages))
+	for i, page := range pages {
+		pagesText[i] = page.Content
 	}
 
 	return pagesText, nil
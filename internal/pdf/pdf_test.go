@@ -0,0 +1,111 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractPagesPageCount(t *testing.T) {
+	sample := SamplePDF("hello pdf-fts")
+	// Same length, so the cross-reference offsets stay valid
+	noPages := bytes.Replace(sample, []byte("/Kids [3 0 R] /Count 1"), []byte("/Kids []      /Count 0"), 1)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []string
+		wantErr error
+	}{
+		{"one page", sample, []string{"hello pdf-fts"}, nil},
+		{"no pages", noPages, nil, ErrNoPages},
+	}
+
+	e := New(false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "doc.pdf")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			pages, err := e.ExtractPages(path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(pages) != len(tt.want) {
+				t.Fatalf("got %d pages, want %d", len(pages), len(tt.want))
+			}
+			for i, page := range pages {
+				if strings.TrimSpace(page.Content) != tt.want[i] {
+					t.Errorf("page %d: got %q, want %q", i+1, page.Content, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		want  string
+		lines string // CleanLines keeps the line breaks
+	}{
+		{"whitespace", "  hello \t world\n\nnext  line ", "hello world next line", "hello world\nnext line"},
+		{"accents and dashes", "café — naïve", "cafe - naive", "cafe - naive"},
+		{"invalid UTF-8 dropped", "bro\xffken \xc3text", "broken text", "broken text"},
+		{"only invalid bytes", "\xff\xfe", "", ""},
+	}
+
+	e := New(false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.CleanText(tt.text); got != tt.want {
+				t.Errorf("CleanText: got %q, want %q", got, tt.want)
+			}
+			if got := e.CleanLines(tt.text); got != tt.lines {
+				t.Errorf("CleanLines: got %q, want %q", got, tt.lines)
+			}
+		})
+	}
+}
+
+func TestExtractPagesAnnotations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	if err := os.WriteFile(path, samplePDF("see the manual", "https://example.com/manual"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		indexAnnotations bool
+		want             string
+	}{
+		{"without annotations", false, "see the manual"},
+		{"with annotations", true, "see the manual https://example.com/manual"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(false)
+			e.IndexAnnotations = tt.indexAnnotations
+			pages, err := e.ExtractPages(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(pages) != 1 || pages[0].Content != tt.want {
+				t.Errorf("got %+v, want one page with content %q", pages, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// LowQuality is the TextQuality below which a page is reported as probably
+// garbled, a hint that the document needs OCR or re-processing
+const LowQuality = 0.5
+
+// minQualityTokens is the number of tokens needed for TextQuality to judge text
+const minQualityTokens = 10
+
+// TextQuality estimates how readable extracted text is, from 0 (garbage) to 1.
+// It averages the share of tokens that look like words, how close the average
+// word length is to natural language and the share of letters and digits
+// among the other characters. Broken spacing ("T h i s") and symbol soup score
+// low, text in the wrong reading order is not detected. Text shorter than
+// minQualityTokens (e.g. a page with only its number) scores 1, there is too
+// little to judge.
+func TextQuality(text string) float64 {
+	// Dot leaders of tables of contents and indexes are layout, not text
+	var tokens []string
+	for _, token := range strings.Fields(text) {
+		if strings.Trim(token, ".") != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) < minQualityTokens {
+		return 1
+	}
+
+	words, wordLetters := 0, 0
+	alnum, visible := 0, 0
+	for _, token := range tokens {
+		for _, r := range token {
+			visible++
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				alnum++
+			}
+		}
+
+		word := strings.TrimFunc(token, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		letters := 0
+		for _, r := range word {
+			if !unicode.IsLetter(r) {
+				letters = 0
+				break
+			}
+			letters++
+		}
+		if letters >= 2 && letters <= 20 {
+			words++
+			wordLetters += letters
+		}
+	}
+
+	wordRatio := float64(words) / float64(len(tokens))
+	alnumRatio := float64(alnum) / float64(visible)
+
+	// Natural language averages 3 to 10 letters per word
+	lengthScore := 0.0
+	if words > 0 {
+		avg := float64(wordLetters) / float64(words)
+		switch {
+		case avg < 3:
+			lengthScore = avg / 3
+		case avg > 10:
+			lengthScore = 10 / avg
+		default:
+			lengthScore = 1
+		}
+	}
+
+	return (wordRatio + lengthScore + alnumRatio) / 3
+}
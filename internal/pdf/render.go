@@ -0,0 +1,80 @@
+package pdf
+
+import (
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// DefaultDPI is the resolution of exported page images
+const DefaultDPI = 150
+
+// jpegQuality is the quality of exported JPEG images
+const jpegQuality = 90
+
+// ImageFormat returns the image format written for a file name, "png" or
+// "jpeg" from its extension, or an error for other extensions
+func ImageFormat(outPath string) (string, error) {
+	switch strings.ToLower(filepath.Ext(outPath)) {
+	case ".png":
+		return "png", nil
+	case ".jpg", ".jpeg":
+		return "jpeg", nil
+	default:
+		return "", fmt.Errorf("unsupported image extension for %s, expected .png, .jpg or .jpeg", outPath)
+	}
+}
+
+// ExportPage renders a page (starting at 1) of a document at the given
+// resolution and writes it to outPath, as PNG or JPEG depending on its extension
+func ExportPage(pdfPath string, pageNum int, dpi float64, outPath string) error {
+	format, err := ImageFormat(outPath)
+	if err != nil {
+		return err
+	}
+
+	doc, err := fitz.New(pdfPath)
+	if err != nil {
+		return fmt.Errorf("opening PDF file %s: %w", pdfPath, mupdfError(err))
+	}
+	defer doc.Close()
+
+	if pageNum < 1 || pageNum > doc.NumPage() {
+		return fmt.Errorf("%s has no page %d, it has %d pages", pdfPath, pageNum, doc.NumPage())
+	}
+
+	img, err := doc.ImageDPI(pageNum-1, dpi)
+	if err != nil {
+		return fmt.Errorf("rendering page %d of %s: %w", pageNum, pdfPath, err)
+	}
+
+	file, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", outPath, err)
+	}
+
+	if format == "jpeg" {
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(file, img)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", outPath, err)
+	}
+	return nil
+}
+
+// PageImageName returns the file name used for the image of a page of a
+// document exported in bulk, like "report-p12.png"
+func PageImageName(pdfPath string, pageNum int, ext string) string {
+	base := filepath.Base(pdfPath)
+	return fmt.Sprintf("%s-p%d%s", strings.TrimSuffix(base, filepath.Ext(base)), pageNum, ext)
+}
@@ -0,0 +1,48 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// SamplePDF returns a minimal one-page PDF showing the given line of text,
+// which must not contain parentheses or backslashes
+func SamplePDF(text string) []byte {
+	return samplePDF(text, "")
+}
+
+// samplePDF is SamplePDF with a link annotation to uri over the text, when
+// not empty
+func samplePDF(text, uri string) []byte {
+	var annots string
+	if uri != "" {
+		annots = " /Annots [6 0 R]"
+	}
+	content := fmt.Sprintf("BT /F1 18 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >>" + annots + " >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	if uri != "" {
+		objects = append(objects, fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [72 710 400 740] /Border [0 0 0] /A << /S /URI /URI (%s) >> >>", uri))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,82 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SplitLongPages splits the pages longer than maxChars runes into segments of
+// at most maxChars runes, cutting at line breaks or spaces when possible, so
+// snippets and ranking behave on documents stored as a few huge pages. Every
+// returned page has PageNum set to the number of the page it comes from. A
+// maxChars of zero or less returns the pages unchanged.
+func SplitLongPages(pages []Page, maxChars int) []Page {
+	if maxChars <= 0 {
+		return pages
+	}
+
+	var segments []Page
+	for i, page := range pages {
+		pageNum := page.PageNum
+		if pageNum == 0 {
+			pageNum = i + 1
+		}
+
+		if utf8.RuneCountInString(page.Content) <= maxChars {
+			page.PageNum = pageNum
+			segments = append(segments, page)
+			continue
+		}
+
+		text := page.Original
+		if text == "" {
+			text = page.Content
+		}
+		for _, segment := range splitText(text, maxChars) {
+			content := strings.Join(strings.Fields(segment), " ")
+			segments = append(segments, Page{
+				Content:  content,
+				Original: segment,
+				PageNum:  pageNum,
+				Quality:  TextQuality(content),
+			})
+		}
+	}
+	return segments
+}
+
+// splitText cuts text into chunks of at most maxChars runes, preferring to
+// cut after a line break, then after a space, and mid-word only as a last resort
+func splitText(text string, maxChars int) []string {
+	var chunks []string
+	for utf8.RuneCountInString(text) > maxChars {
+		// Byte offset of the first rune past the budget
+		limit := len(text)
+		count := 0
+		for offset := range text {
+			if count == maxChars {
+				limit = offset
+				break
+			}
+			count++
+		}
+
+		cut := strings.LastIndexByte(text[:limit], '\n')
+		if cut <= 0 {
+			cut = strings.LastIndexByte(text[:limit], ' ')
+		}
+		if cut <= 0 {
+			cut = limit
+		}
+
+		chunk := strings.TrimSpace(text[:cut])
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		text = strings.TrimLeft(text[cut:], " \n")
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/aziis98/pdf-fts/internal/database"
+	"github.com/aziis98/pdf-fts/internal/pdf"
+	"github.com/aziis98/pdf-fts/internal/util"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -14,9 +16,10 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-var (
-	spaceNormalizer = regexp.MustCompile(`\s+`)
+// maxSnippetCells caps the display width of a single page snippet
+const maxSnippetCells = 4 * 90
 
+var (
 	// Lipgloss styles
 	docStyle = lipgloss.NewStyle().
 			Margin(1, 2, 0, 2)
@@ -52,6 +55,16 @@ var (
 type UI struct {
 	db      *database.DB
 	verbose bool
+
+	// RelativePaths shows absolute paths relative to the working directory
+	RelativePaths bool
+
+	// Cache serves repeated queries, the database is queried directly when nil
+	Cache *database.SearchCache
+
+	// MatchQuery builds the FTS5 MATCH expression of a typed query, the query
+	// is passed as typed when nil
+	MatchQuery func(query string) (string, error)
 }
 
 // New creates a new UI handler
@@ -79,8 +92,11 @@ type fileResult struct {
 }
 
 type pageResult struct {
-	PageNum int
-	Snippet string
+	PageNum    int
+	PageOffset int
+	Snippet    string
+	// Position is where the first match is in the page, from 0 to 1, or -1
+	Position float64
 }
 
 type liveSearchModel struct {
@@ -92,10 +108,21 @@ type liveSearchModel struct {
 	height              int
 	err                 error
 	db                  *database.DB
+	cache               *database.SearchCache
+	matchQuery          func(query string) (string, error)
 	verbose             bool
 	results             []fileResult
 	lastNonEmptyResults []fileResult
 	query               string
+
+	// typeFilters are the selectable file extensions, the empty string means all files
+	typeFilters []string
+	typeFilter  int
+
+	relativePaths bool
+
+	// status reports the outcome of the last page export
+	status string
 }
 
 type searchResultsMsg struct {
@@ -106,6 +133,11 @@ type searchResultsMsg struct {
 
 type searchErrorMsg struct{ err error }
 
+type exportPageMsg struct {
+	out string
+	err error
+}
+
 func (u *UI) initialLiveSearchModel() liveSearchModel {
 	ti := textinput.New()
 	ti.Placeholder = "Search PDFs..."
@@ -123,14 +155,26 @@ func (u *UI) initialLiveSearchModel() liveSearchModel {
 		BorderForeground(lipgloss.Color("62")).
 		PaddingRight(2)
 
+	// The filter cycles through all files and then each indexed extension
+	typeFilters := []string{""}
+	if u.db != nil {
+		if extensions, err := u.db.FileExtensions(); err == nil {
+			typeFilters = append(typeFilters, extensions...)
+		}
+	}
+
 	return liveSearchModel{
+		typeFilters:         typeFilters,
 		width:               80,
 		textInput:           ti,
 		spinner:             s,
 		viewport:            vp,
 		searching:           false,
 		db:                  u.db,
+		cache:               u.Cache,
+		matchQuery:          u.MatchQuery,
 		verbose:             u.verbose,
+		relativePaths:       u.RelativePaths,
 		results:             []fileResult{},
 		lastNonEmptyResults: []fileResult{},
 	}
@@ -150,8 +194,8 @@ func (m liveSearchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.textInput.Width = msg.Width - 20 // Leave some margin
 
 		// Update viewport size - reserve space for header, search box, and help
-		headerHeight := 4 // Header + search box + spacing
-		footerHeight := 2 // Help text
+		headerHeight := 5 // Header + search box + filter + spacing
+		footerHeight := 3 // Help text and export status
 		availableHeight := m.height - headerHeight - footerHeight
 		m.viewport.Width = msg.Width - 4
 		m.viewport.Height = max(5, availableHeight)
@@ -163,6 +207,21 @@ func (m liveSearchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			// Handle item selection here if needed
 			return m, nil
+		case "tab":
+			// Cycle the file type filter and refresh the results
+			m.typeFilter = (m.typeFilter + 1) % len(m.typeFilters)
+			if query := m.textInput.Value(); strings.TrimSpace(query) != "" {
+				m.searching = true
+				return m, m.performSearchCmd(query)
+			}
+			return m, nil
+		case "ctrl+e":
+			// Export the best page of the top result for a visual check
+			if len(m.results) > 0 && len(m.results[0].Pages) > 0 {
+				m.status = "Exporting..."
+				return m, exportPageCmd(m.results[0].Path, m.results[0].Pages[0].PageNum)
+			}
+			return m, nil
 		case "up", "k":
 			// Let viewport handle scrolling
 			m.viewport.ScrollUp(1)
@@ -210,6 +269,13 @@ func (m liveSearchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.viewport.SetContent(m.renderResults())
 		m.viewport.GotoTop()
 
+	case exportPageMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Export failed: %v", msg.err)
+		} else {
+			m.status = "Exported " + msg.out
+		}
+
 	case searchErrorMsg:
 		m.searching = false
 		m.err = msg.err
@@ -260,6 +326,7 @@ func (m liveSearchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m liveSearchModel) View() string {
 	// Search input
 	content := searchBoxStyle.Render(fmt.Sprintf("Search: %s", m.textInput.View())) + "\n"
+	content += helpStyle.Render(fmt.Sprintf(" Type: %s", m.typeFilterLabel())) + "\n"
 
 	// Status and results
 	if m.searching {
@@ -279,11 +346,23 @@ func (m liveSearchModel) View() string {
 	content += m.viewport.View()
 
 	// Help text
-	content += "\n\n" + helpStyle.Render("Press ctrl+c/esc to quit")
+	content += "\n\n" + helpStyle.Render("Press tab to change the file type • ctrl+e to export the top page as an image • ctrl+c/esc to quit")
+	if m.status != "" {
+		content += "\n" + helpStyle.Render(m.status)
+	}
 
 	return docStyle.Render(content)
 }
 
+// typeFilterLabel returns a description of the active file type filter
+func (m liveSearchModel) typeFilterLabel() string {
+	ext := m.typeFilters[m.typeFilter]
+	if ext == "" {
+		return "all"
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
 func (m liveSearchModel) highlightMatches(snippet, queryTerm string) string {
 	highlightStyle := lipgloss.NewStyle().
 		Background(lipgloss.AdaptiveColor{Light: "7", Dark: "8"}).
@@ -315,6 +394,15 @@ func (m liveSearchModel) highlightMatches(snippet, queryTerm string) string {
 	return highlighted
 }
 
+// exportPageCmd renders a page to an image in the current directory
+func exportPageCmd(path string, pageNum int) tea.Cmd {
+	return func() tea.Msg {
+		out := pdf.PageImageName(path, pageNum, ".png")
+		err := pdf.ExportPage(path, pageNum, pdf.DefaultDPI, out)
+		return exportPageMsg{out: out, err: err}
+	}
+}
+
 func (m liveSearchModel) performSearchCmd(queryTerm string) tea.Cmd {
 	return func() tea.Msg {
 		if m.db == nil {
@@ -333,7 +421,24 @@ func (m liveSearchModel) queryDBForLiveSearch(queryTerm string, limit int) ([]fi
 		return []fileResult{}, nil
 	}
 
-	searchResults, err := m.db.Search(queryTerm, limit)
+	matchQuery := queryTerm
+	if m.matchQuery != nil {
+		var err error
+		if matchQuery, err = m.matchQuery(queryTerm); err != nil {
+			return nil, err
+		}
+	}
+
+	search := m.db.Search
+	if m.cache != nil {
+		search = m.cache.Search
+	}
+	searchResults, err := search(matchQuery, database.SearchOptions{
+		Limit:           limit,
+		Extension:       m.typeFilters[m.typeFilter],
+		SnippetEllipsis: database.DefaultSnippetEllipsis,
+		PositionTerms:   util.QueryWords(queryTerm),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("search query failed: %w", err)
 	}
@@ -350,8 +455,10 @@ func (m liveSearchModel) queryDBForLiveSearch(queryTerm string, limit int) ([]fi
 		}
 
 		pageRes := pageResult{
-			PageNum: result.PageNum,
-			Snippet: result.Snippet,
+			PageNum:    result.PageNum,
+			PageOffset: result.PageOffset,
+			Snippet:    result.Snippet,
+			Position:   result.Position,
 		}
 		resultMap[result.Path].Pages = append(resultMap[result.Path].Pages, pageRes)
 	}
@@ -388,20 +495,34 @@ func (m liveSearchModel) renderResults() string {
 			base = base[:maxBaseLen-3] + "..."
 		}
 
+		displayPath := fileResult.Path
+		if m.relativePaths {
+			displayPath = util.RelativePath(displayPath)
+		}
+
 		baseWithPath := fmt.Sprintf("%s\n%s",
 			fileStyle.Render(base),
-			pathStyle.Render(filepath.Dir(fileResult.Path)+"/"))
+			pathStyle.Render(filepath.Dir(displayPath)+"/"))
 
 		// Combine page snippets
 		var pageSnippets []string
 		for _, page := range fileResult.Pages {
-			snippet := strings.ReplaceAll(page.Snippet, "\n", " ")
-			snippet = spaceNormalizer.ReplaceAllString(snippet, " ")
+			snippet := util.CleanSnippet(page.Snippet, database.DefaultSnippetEllipsis)
+			snippet = util.FitSnippet(snippet, maxSnippetCells)
 			highlightedSnippet := m.highlightMatches(snippet, m.query)
+			if page.Position >= 0 {
+				// Tells whether to look at the top or the bottom of the page
+				highlightedSnippet = pathStyle.Render(fmt.Sprintf("(%d%%)", int(page.Position*100))) +
+					" " + highlightedSnippet
+			}
+			if page.PageOffset != 0 {
+				highlightedSnippet = pathStyle.Render(fmt.Sprintf("(PDF page %d)", page.PageNum)) +
+					" " + highlightedSnippet
+			}
 
 			// Format snippet with page number using JoinHorizontal like search.go
 			formattedSnippet := lipgloss.JoinHorizontal(lipgloss.Left,
-				pageStyle.Render(fmt.Sprintf("p.%d", page.PageNum)),
+				pageStyle.Render("p."+database.PrintedPage(page.PageNum, page.PageOffset)),
 				" ",
 				lipgloss.NewStyle().
 					Width(90).
@@ -1,6 +1,17 @@
 package util
 
-import "strings"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
 
 // Dedent removes leading and trailing whitespace from each line, also trims any initial and trailing whitespace from the entire string.
 func Dedent(s string) string {
@@ -14,3 +25,189 @@ func Dedent(s string) string {
 	}
 	return strings.Join(result, "\n")
 }
+
+// Levenshtein returns the edit distance between two strings, counting runes
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// FoldPath lowercases a path and removes its diacritics, for case and accent
+// insensitive comparisons
+func FoldPath(path string) string {
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(folder, path)
+	if err != nil {
+		folded = path
+	}
+	return strings.ToLower(folded)
+}
+
+// RelativePath returns an absolute path relative to the working directory for
+// display, relative paths and paths that can't be made relative are unchanged
+func RelativePath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// QueryWords returns the words of a full-text query without quotes, prefix
+// stars, parentheses and the FTS operators
+func QueryWords(query string) []string {
+	var words []string
+	for _, word := range strings.Fields(query) {
+		word = strings.Trim(word, `"*()`)
+		switch word {
+		case "", "AND", "OR", "NOT", "NEAR":
+			continue
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// Highlight markers wrapped around matches in snippets
+const (
+	HighlightStart = "[HL]"
+	HighlightEnd   = "[/HL]"
+)
+
+// invisibleRunes removes zero-width formatting characters (joiners, direction
+// marks, variation selectors, byte order marks) that terminals and width
+// calculations disagree on
+var invisibleRunes = strings.NewReplacer(
+	"\u200b", "", "\u200c", "", "\u200d", "", "\u200e", "", "\u200f", "",
+	"\u2060", "", "\ufeff", "", "\ufe0e", "", "\ufe0f", "",
+)
+
+// CleanSnippet collapses the whitespace of a snippet into single spaces and
+// tidies the ellipsis marking where it was cut: repeated ones are merged and
+// the spaces between them and the text dropped, so "... ...foo  bar ..."
+// becomes "...foo bar...". An empty ellipsis only collapses the whitespace.
+func CleanSnippet(snippet, ellipsis string) string {
+	snippet = strings.Join(strings.Fields(snippet), " ")
+	if ellipsis == "" {
+		return snippet
+	}
+
+	leading, trailing := false, false
+	for {
+		if rest, ok := strings.CutPrefix(snippet, ellipsis); ok {
+			snippet, leading = strings.TrimSpace(rest), true
+		} else if rest, ok := strings.CutSuffix(snippet, ellipsis); ok {
+			snippet, trailing = strings.TrimSpace(rest), true
+		} else {
+			break
+		}
+	}
+	if snippet == "" {
+		return ""
+	}
+
+	if leading {
+		snippet = ellipsis + snippet
+	}
+	if trailing {
+		snippet += ellipsis
+	}
+	return snippet
+}
+
+// FitSnippet removes zero-width formatting characters from a snippet and cuts
+// it to at most maxCells terminal cells, counting wide characters (CJK, emoji)
+// as two, so fixed width boxes stay aligned. Highlight markers don't count
+// and a highlight cut short is closed. When the first highlight would fall in
+// the second half, the start of the snippet is dropped to keep it visible.
+func FitSnippet(snippet string, maxCells int) string {
+	snippet = invisibleRunes.Replace(snippet)
+
+	visible := strings.NewReplacer(HighlightStart, "", HighlightEnd, "").Replace(snippet)
+	if runewidth.StringWidth(visible) <= maxCells {
+		return snippet
+	}
+
+	var b strings.Builder
+
+	// Scroll so the first highlight starts a quarter into the snippet
+	if start := strings.Index(snippet, HighlightStart); start > 0 {
+		lead := runewidth.StringWidth(snippet[:start])
+		if lead > maxCells/2 {
+			for lead > maxCells/4 {
+				r, size := utf8.DecodeRuneInString(snippet)
+				lead -= runewidth.RuneWidth(r)
+				snippet = snippet[size:]
+			}
+			snippet = strings.TrimLeft(snippet, " ")
+			b.WriteString("...")
+			maxCells -= 3
+			visible = strings.NewReplacer(HighlightStart, "", HighlightEnd, "").Replace(snippet)
+			if runewidth.StringWidth(visible) <= maxCells {
+				return b.String() + snippet
+			}
+		}
+	}
+
+	cells := 0
+	highlighted := false
+	for i := 0; i < len(snippet); {
+		if strings.HasPrefix(snippet[i:], HighlightStart) {
+			b.WriteString(HighlightStart)
+			highlighted = true
+			i += len(HighlightStart)
+			continue
+		}
+		if strings.HasPrefix(snippet[i:], HighlightEnd) {
+			b.WriteString(HighlightEnd)
+			highlighted = false
+			i += len(HighlightEnd)
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(snippet[i:])
+		width := runewidth.RuneWidth(r)
+		if cells+width > maxCells-3 { // Keep room for the ellipsis
+			break
+		}
+		b.WriteRune(r)
+		cells += width
+		i += size
+	}
+	fitted := b.String()
+	if highlighted && strings.HasSuffix(fitted, HighlightStart) {
+		// Cut right at the start of a highlight
+		fitted, highlighted = strings.TrimSuffix(fitted, HighlightStart), false
+	}
+	fitted = strings.TrimRight(fitted, " ")
+	if highlighted {
+		fitted += HighlightEnd
+	}
+	return fitted + "..."
+}
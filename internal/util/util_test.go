@@ -0,0 +1,55 @@
+package util
+
+import "testing"
+
+func TestCleanSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  string
+		ellipsis string
+		want     string
+	}{
+		{"repeated ellipses merged", "... ...foo  bar ...", "...", "...foo bar..."},
+		{"no ellipsis", "foo\n\tbar ", "...", "foo bar"},
+		{"empty ellipsis collapses whitespace", " ... foo  ... ", "", "... foo ..."},
+		{"only ellipses", "... ...", "...", ""},
+		{"middle ellipsis kept", "foo ... bar", "...", "foo ... bar"},
+		{"custom ellipsis", "… foo …", "…", "…foo…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanSnippet(tt.snippet, tt.ellipsis); got != tt.want {
+				t.Errorf("CleanSnippet(%q, %q) = %q, want %q", tt.snippet, tt.ellipsis, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  string
+		maxCells int
+		want     string
+	}{
+		{"fits", "hello [HL]world[/HL]", 11, "hello [HL]world[/HL]"},
+		{"zero-width characters removed", "a\u200bb\ufeffc", 10, "abc"},
+		{"cut with an ellipsis", "abcdefghij", 8, "abcde..."},
+		{"trailing space trimmed", "abcd fghij", 8, "abcd..."},
+		{"wide characters count as two", "日本語テキスト", 10, "日本語..."},
+		{"emoji count as two", "😀😀😀😀😀😀", 10, "😀😀😀..."},
+		{"emoji joiners and variation selectors removed", "👩\u200d💻 ❤\ufe0f go", 12, "👩💻 ❤ go"},
+		{"joined emoji cut between parts", "👩\u200d👩\u200d👧\u200d👦 family", 8, "👩👩..."},
+		{"highlight cut short is closed", "ab[HL]cdefgh[/HL]ij", 8, "ab[HL]cde[/HL]..."},
+		{"late highlight scrolled into view", "0123456789012345[HL]x[/HL]", 12, "...345[HL]x[/HL]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FitSnippet(tt.snippet, tt.maxCells); got != tt.want {
+				t.Errorf("FitSnippet(%q, %d) = %q, want %q", tt.snippet, tt.maxCells, got, tt.want)
+			}
+		})
+	}
+}
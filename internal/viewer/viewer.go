@@ -0,0 +1,61 @@
+package viewer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// EnvVar is the environment variable holding the viewer command, where
+// "{path}" and "{page}" are replaced with the file and the page to open, e.g.
+// "zathura --page={page} {path}"
+const EnvVar = "PDF_FTS_VIEWER"
+
+// Open opens a PDF at the given page with the configured viewer, falling back
+// to the system default application (which usually ignores the page)
+func Open(path string, page int) error {
+	var cmd *exec.Cmd
+	if template := strings.TrimSpace(os.Getenv(EnvVar)); template != "" {
+		args := expandTemplate(template, path, page)
+		cmd = exec.Command(args[0], args[1:]...)
+	} else {
+		cmd = defaultCommand(path)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("opening %s with %s: %w", path, cmd.Path, err)
+	}
+	// The viewer outlives the command, don't wait for it
+	return cmd.Process.Release()
+}
+
+// expandTemplate splits the viewer command into arguments and substitutes the
+// placeholders, appending the path when the template doesn't mention it
+func expandTemplate(template, path string, page int) []string {
+	replacer := strings.NewReplacer("{path}", path, "{page}", strconv.Itoa(page))
+
+	hasPath := strings.Contains(template, "{path}")
+	var args []string
+	for _, field := range strings.Fields(template) {
+		args = append(args, replacer.Replace(field))
+	}
+	if !hasPath {
+		args = append(args, path)
+	}
+	return args
+}
+
+// defaultCommand returns the command opening a file with the default application
+func defaultCommand(path string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", path)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		return exec.Command("xdg-open", path)
+	}
+}